@@ -0,0 +1,359 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Header is the first line expected by yip to detect a cloud-config file
+	Header = "#cloud-config"
+
+	// Elemental stages, as executed by the elemental-setup services
+	StageRootfs             = "rootfs"
+	StageInitramfs          = "initramfs"
+	StageFs                 = "fs"
+	StageBoot               = "boot"
+	StageNetwork            = "network"
+	StageReconcile          = "reconcile"
+	StageBeforeInstall      = "before-install"
+	StageAfterInstall       = "after-install"
+	StageAfterInstallChroot = "after-install-chroot"
+	StageBeforeUpgrade      = "before-upgrade"
+	StageAfterUpgrade       = "after-upgrade"
+	StageAfterUpgradeChroot = "after-upgrade-chroot"
+	StageBeforeReset        = "before-reset"
+	StageAfterReset         = "after-reset"
+	StageAfterResetChroot   = "after-reset-chroot"
+)
+
+// stepKeys lists the keys of a yip stage step, the keys without a Stage
+// field are kept in Stage.Extra
+var stepKeys = map[string]bool{
+	"name": true, "if": true, "if_files": true, "if_check": true, "only_os": true, "only_os_version": true,
+	"before": true, "after": true, "commands": true, "files": true, "downloads": true, "directories": true,
+	"users": true, "ensure_entities": true, "delete_entities": true, "dns": true, "hostname": true,
+	"sysctl": true, "authorized_keys": true, "node": true, "modules": true, "systemctl": true,
+	"environment": true, "environment_file": true, "datasource": true, "layout": true,
+	"systemd_firstboot": true, "timesyncd": true, "git": true, "unpack_images": true, "packages": true,
+}
+
+// configKeys lists the cloud-init keys accepted by yip without a Config
+// field, they are kept in Config.Extra
+var configKeys = map[string]bool{
+	"bootcmd": true, "growpart": true,
+}
+
+// knownStages lists the stages that elemental is able to run
+var knownStages = []string{
+	StageRootfs, StageInitramfs, StageFs, StageBoot, StageNetwork, StageReconcile,
+	StageBeforeInstall, StageAfterInstall, StageAfterInstallChroot,
+	StageBeforeUpgrade, StageAfterUpgrade, StageAfterUpgradeChroot,
+	StageBeforeReset, StageAfterReset, StageAfterResetChroot,
+}
+
+// Config is an Elemental cloud-config, it accepts both the cloud-init
+// compatible keys and the yip stages
+type Config struct {
+	Name              string             `yaml:"name,omitempty"`
+	Hostname          string             `yaml:"hostname,omitempty"`
+	SSHAuthorizedKeys []string           `yaml:"ssh_authorized_keys,omitempty"`
+	Users             []User             `yaml:"users,omitempty"`
+	WriteFiles        []WriteFile        `yaml:"write_files,omitempty"`
+	RunCmd            []string           `yaml:"runcmd,omitempty"`
+	Stages            map[string][]Stage `yaml:"stages,omitempty"`
+	// Extra keeps the other cloud-init keys, e.g. bootcmd or growpart
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// User is a cloud-init user entry
+type User struct {
+	Name              string   `yaml:"name"`
+	Passwd            string   `yaml:"passwd,omitempty"`
+	LockPasswd        bool     `yaml:"lock_passwd,omitempty"`
+	Groups            []string `yaml:"groups,omitempty"`
+	PrimaryGroup      string   `yaml:"primary_group,omitempty"`
+	Homedir           string   `yaml:"homedir,omitempty"`
+	Shell             string   `yaml:"shell,omitempty"`
+	UID               string   `yaml:"uid,omitempty"`
+	System            bool     `yaml:"system,omitempty"`
+	NoCreateHome      bool     `yaml:"no_create_home,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+// WriteFile is a cloud-init write_files entry
+type WriteFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content,omitempty"`
+	Encoding    string `yaml:"encoding,omitempty"`
+	Owner       string `yaml:"owner,omitempty"`
+	Permissions string `yaml:"permissions,omitempty"`
+	Append      bool   `yaml:"append,omitempty"`
+}
+
+// Stage is a yip stage step
+type Stage struct {
+	Name            string                 `yaml:"name,omitempty"`
+	If              string                 `yaml:"if,omitempty"`
+	Commands        []string               `yaml:"commands,omitempty"`
+	Files           []File                 `yaml:"files,omitempty"`
+	Directories     []Directory            `yaml:"directories,omitempty"`
+	Users           map[string]StageUser   `yaml:"users,omitempty"`
+	SSHKeys         map[string][]string    `yaml:"authorized_keys,omitempty"`
+	Hostname        string                 `yaml:"hostname,omitempty"`
+	Modules         []string               `yaml:"modules,omitempty"`
+	Sysctl          map[string]string      `yaml:"sysctl,omitempty"`
+	Environment     map[string]string      `yaml:"environment,omitempty"`
+	EnvironmentFile string                 `yaml:"environment_file,omitempty"`
+	Systemctl       *Systemctl             `yaml:"systemctl,omitempty"`
+	TimeSyncd       map[string]string      `yaml:"timesyncd,omitempty"`
+	Layout          map[string]interface{} `yaml:"layout,omitempty"`
+	// Extra keeps the yip keys without a field, e.g. dns or packages
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// File is a file created by a yip stage
+type File struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content,omitempty"`
+	Encoding    string `yaml:"encoding,omitempty"`
+	Permissions uint32 `yaml:"permissions,omitempty"`
+	Owner       int    `yaml:"owner,omitempty"`
+	Group       int    `yaml:"group,omitempty"`
+	OwnerString string `yaml:"owner_string,omitempty"`
+}
+
+// Directory is a directory created by a yip stage
+type Directory struct {
+	Path        string `yaml:"path"`
+	Permissions uint32 `yaml:"permissions,omitempty"`
+	Owner       int    `yaml:"owner,omitempty"`
+	Group       int    `yaml:"group,omitempty"`
+}
+
+// StageUser is a user created by a yip stage
+type StageUser struct {
+	Name              string   `yaml:"name,omitempty"`
+	PasswordHash      string   `yaml:"passwd,omitempty"`
+	LockPasswd        bool     `yaml:"lock_passwd,omitempty"`
+	Groups            []string `yaml:"groups,omitempty"`
+	PrimaryGroup      string   `yaml:"primary_group,omitempty"`
+	Homedir           string   `yaml:"homedir,omitempty"`
+	Shell             string   `yaml:"shell,omitempty"`
+	UID               string   `yaml:"uid,omitempty"`
+	System            bool     `yaml:"system,omitempty"`
+	NoCreateHome      bool     `yaml:"no_create_home,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+// Systemctl defines the systemd units to handle in a yip stage
+type Systemctl struct {
+	Enable  []string `yaml:"enable,omitempty"`
+	Disable []string `yaml:"disable,omitempty"`
+	Start   []string `yaml:"start,omitempty"`
+	Mask    []string `yaml:"mask,omitempty"`
+}
+
+// AddStage appends the given steps to a stage
+func (c *Config) AddStage(name string, steps ...Stage) *Config {
+	if c.Stages == nil {
+		c.Stages = map[string][]Stage{}
+	}
+	c.Stages[name] = append(c.Stages[name], steps...)
+	return c
+}
+
+// Marshal returns the cloud-config YAML, including the cloud-config header
+func (c *Config) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(Header + "\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, errors.Wrap(err, "marshalling cloud-config")
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses a cloud-config, the keys without a field are kept in
+// the Extra maps so configs read from a SUT can be compared, see Validate
+// to report unknown keys
+func Unmarshal(data []byte) (*Config, error) {
+	c := &Config{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "parsing cloud-config")
+	}
+
+	return c, nil
+}
+
+// ValidateData parses and validates a raw cloud-config
+func ValidateData(data []byte) error {
+	c, err := Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate checks the top-level keys, the stage names, the keys of the
+// stage steps, the paths, permissions and encodings of the files and the
+// commands.
+func (c *Config) Validate() error {
+	var messages []string
+
+	keys := make([]string, 0, len(c.Extra))
+	for key := range c.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !configKeys[key] {
+			messages = append(messages, fmt.Sprintf("unknown key '%s'", key))
+		}
+	}
+
+	for i, u := range c.Users {
+		if u.Name == "" {
+			messages = append(messages, fmt.Sprintf("users[%d]: name is required", i))
+		}
+	}
+
+	for i, f := range c.WriteFiles {
+		messages = append(messages, validatePath(fmt.Sprintf("write_files[%d]", i), f.Path)...)
+		if f.Permissions != "" && !isOctal(f.Permissions) {
+			messages = append(messages, fmt.Sprintf("write_files[%d]: invalid permissions '%s'", i, f.Permissions))
+		}
+		messages = append(messages, validateEncoding(fmt.Sprintf("write_files[%d]", i), f.Encoding)...)
+	}
+
+	// Sort stages to get a stable error message
+	names := make([]string, 0, len(c.Stages))
+	for name := range c.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !IsKnownStage(name) {
+			messages = append(messages, fmt.Sprintf("stages: unknown stage '%s'", name))
+		}
+		for i, s := range c.Stages[name] {
+			messages = append(messages, s.validate(fmt.Sprintf("stages.%s[%d]", name, i))...)
+		}
+	}
+
+	if len(messages) > 0 {
+		return errors.Errorf("invalid cloud-config: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// validate checks a single yip stage step
+func (s Stage) validate(prefix string) []string {
+	var messages []string
+
+	for i, f := range s.Files {
+		messages = append(messages, validatePath(fmt.Sprintf("%s.files[%d]", prefix, i), f.Path)...)
+		if f.Permissions > 07777 {
+			messages = append(messages, fmt.Sprintf("%s.files[%d]: invalid permissions %o", prefix, i, f.Permissions))
+		}
+		messages = append(messages, validateEncoding(fmt.Sprintf("%s.files[%d]", prefix, i), f.Encoding)...)
+	}
+
+	for i, d := range s.Directories {
+		messages = append(messages, validatePath(fmt.Sprintf("%s.directories[%d]", prefix, i), d.Path)...)
+		if d.Permissions > 07777 {
+			messages = append(messages, fmt.Sprintf("%s.directories[%d]: invalid permissions %o", prefix, i, d.Permissions))
+		}
+	}
+
+	keys := make([]string, 0, len(s.Extra))
+	for key := range s.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !stepKeys[key] {
+			messages = append(messages, fmt.Sprintf("%s: unknown key '%s'", prefix, key))
+		}
+	}
+
+	for i, cmd := range s.Commands {
+		if strings.TrimSpace(cmd) == "" {
+			messages = append(messages, fmt.Sprintf("%s.commands[%d]: empty command", prefix, i))
+		}
+	}
+
+	return messages
+}
+
+// IsKnownStage returns true if the stage can be run by elemental,
+// '.before' and '.after' variants are accepted too
+func IsKnownStage(name string) bool {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".before"), ".after")
+	for _, s := range knownStages {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePath(prefix, path string) []string {
+	if path == "" {
+		return []string{fmt.Sprintf("%s: path is required", prefix)}
+	}
+	if !filepath.IsAbs(path) {
+		return []string{fmt.Sprintf("%s: path '%s' is not absolute", prefix, path)}
+	}
+	return nil
+}
+
+func validateEncoding(prefix, encoding string) []string {
+	switch encoding {
+	case "", "b64", "base64", "gz", "gzip", "gz+base64", "gzip+base64", "gz+b64", "gzip+b64":
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: unsupported encoding '%s'", prefix, encoding)}
+}
+
+func isOctal(s string) bool {
+	if len(s) == 0 || len(s) > 5 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '7' {
+			return false
+		}
+	}
+	return true
+}
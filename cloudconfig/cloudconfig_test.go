@@ -0,0 +1,95 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudconfig_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/cloudconfig"
+)
+
+var _ = Describe("Cloud-config tests", func() {
+	var cfg *cloudconfig.Config
+
+	BeforeEach(func() {
+		cfg = &cloudconfig.Config{
+			Name:  "test",
+			Users: []cloudconfig.User{{Name: "root", Passwd: "root"}},
+			WriteFiles: []cloudconfig.WriteFile{
+				{Path: "/etc/motd", Content: "hello", Permissions: "0644"},
+			},
+			RunCmd: []string{"echo runcmd"},
+		}
+		cfg.AddStage(cloudconfig.StageInitramfs, cloudconfig.Stage{
+			Name:     "Set hostname",
+			Hostname: "node-01",
+			Files:    []cloudconfig.File{{Path: "/etc/issue", Content: "test", Permissions: 0644}},
+		})
+	})
+
+	It("Marshals and unmarshals a cloud-config", func() {
+		data, err := cfg.Marshal()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(HavePrefix(cloudconfig.Header + "\n"))
+		Expect(string(data)).To(ContainSubstring("write_files:"))
+		Expect(string(data)).To(ContainSubstring("initramfs:"))
+
+		back, err := cloudconfig.Unmarshal(data)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(cloudconfig.Diff(cfg, back)).To(BeEmpty())
+	})
+
+	It("Validates a cloud-config", func() {
+		Expect(cfg.Validate()).To(Succeed())
+
+		cfg.AddStage("unknown", cloudconfig.Stage{Commands: []string{"true"}})
+		cfg.WriteFiles = append(cfg.WriteFiles, cloudconfig.WriteFile{Path: "relative", Permissions: "999"})
+		err := cfg.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unknown stage 'unknown'"))
+		Expect(err.Error()).To(ContainSubstring("path 'relative' is not absolute"))
+		Expect(err.Error()).To(ContainSubstring("invalid permissions '999'"))
+
+		Expect(cloudconfig.IsKnownStage("network.before")).To(BeTrue())
+	})
+
+	It("Keeps yip keys and rejects unknown keys", func() {
+		data := []byte("stages:\n  boot:\n    - name: test\n      dns:\n        nameservers: [\"8.8.8.8\"]\n      packages:\n        install: [\"vim\"]\n")
+		c, err := cloudconfig.Unmarshal(data)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(c.Validate()).To(Succeed())
+		Expect(c.Stages["boot"][0].Extra).To(HaveKey("dns"))
+
+		back, err := c.Marshal()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(back)).To(ContainSubstring("nameservers:"))
+
+		err = cloudconfig.ValidateData([]byte("stages:\n  boot:\n    - name: test\n      comands: [\"true\"]\n"))
+		Expect(err).To(MatchError(ContainSubstring("unknown key 'comands'")))
+	})
+
+	It("Rejects unknown top-level keys", func() {
+		Expect(cloudconfig.ValidateData([]byte("bootcmd:\n  - echo boot\ngrowpart:\n  mode: auto\n"))).To(Succeed())
+
+		err := cloudconfig.ValidateData([]byte("stage:\n  boot:\n    - name: test\n"))
+		Expect(err).To(MatchError("invalid cloud-config: unknown key 'stage'"))
+	})
+
+	It("Reports differences", func() {
+		other := *cfg
+		other.RunCmd = []string{"echo other"}
+		Expect(cloudconfig.Diff(cfg, &other)).To(ContainSubstring("echo other"))
+	})
+})
@@ -0,0 +1,121 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+
+	"github.com/rancher-sandbox/ele-testhelpers/vm"
+)
+
+const (
+	// OEMPath is the persistent directory read by elemental at each boot
+	OEMPath = "/oem"
+	// SystemOEMPath is the read-only directory shipped in the OS image
+	SystemOEMPath = "/system/oem"
+)
+
+// Install validates the cloud-config and copies it on the SUT, if path is
+// a directory (like OEMPath) the file is named after the config name
+func (c *Config) Install(s *vm.SUT, path string) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	data, err := c.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml" {
+		name := c.Name
+		if name == "" {
+			name = "ele-testhelpers"
+		}
+		path = filepath.Join(path, fmt.Sprintf("99_%s.yaml", sanitize(name)))
+	}
+
+	f, err := os.CreateTemp("", "cloud-config-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if _, err := s.Command("mkdir -p " + shellQuote(filepath.Dir(path))); err != nil {
+		return "", errors.Wrapf(err, "creating directory for %s", path)
+	}
+	if err := s.SendFile(f.Name(), path, "0644"); err != nil {
+		return "", errors.Wrapf(err, "sending cloud-config to %s", path)
+	}
+
+	return path, nil
+}
+
+// FromSUT reads back a cloud-config file from the SUT
+func FromSUT(s *vm.SUT, path string) (*Config, error) {
+	out, err := s.Command("cat " + shellQuote(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", path)
+	}
+
+	return Unmarshal([]byte(out))
+}
+
+// Diff returns a human readable diff between two cloud-configs,
+// an empty string means both are equal
+func Diff(expected, actual *Config) string {
+	return cmp.Diff(expected, actual, cmpopts.EquateEmpty())
+}
+
+// DiffOnSUT compares the cloud-config with the one stored on the SUT
+func (c *Config) DiffOnSUT(s *vm.SUT, path string) (string, error) {
+	applied, err := FromSUT(s, path)
+	if err != nil {
+		return "", err
+	}
+
+	return Diff(c, applied), nil
+}
+
+// sanitize makes the name usable as a file name
+func sanitize(name string) string {
+	b := []byte(name)
+	for i, r := range b {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// shellQuote quotes a path for the shell of the SUT
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,166 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Patch types supported by Patch
+const (
+	JSONPatch           = types.JSONPatchType
+	MergePatch          = types.MergePatchType
+	StrategicMergePatch = types.StrategicMergePatchType
+)
+
+// ApplyOptions configures server-side apply
+type ApplyOptions struct {
+	// FieldManager owns the applied fields, FieldManager is used if empty
+	FieldManager string
+	// Force takes the ownership of fields conflicting with other managers
+	Force bool
+	// DryRun validates the request without persisting the objects
+	DryRun bool
+}
+
+// PatchOptions configures Patch
+type PatchOptions struct {
+	// FieldManager is recorded as the manager of the patched fields
+	FieldManager string
+	// Subresource patches a subresource, e.g. "status"
+	Subresource string
+	// DryRun validates the request without persisting the object
+	DryRun bool
+}
+
+func (o ApplyOptions) patchOptions() metav1.PatchOptions {
+	opts := metav1.PatchOptions{FieldManager: o.FieldManager, Force: &o.Force}
+	if opts.FieldManager == "" {
+		opts.FieldManager = FieldManager
+	}
+	if o.DryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	return opts
+}
+
+// ServerSideApply applies the object with server-side apply and returns the
+// object as stored by the API server. obj can be a typed object, an
+// unstructured object, a map or a YAML or JSON document.
+func (k *Kubectl) ServerSideApply(namespace string, obj interface{}, opts ApplyOptions) (*unstructured.Unstructured, error) {
+	data, err := toManifest(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := k.ServerSideApplyManifest(namespace, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) != 1 {
+		return nil, errors.Errorf("expected one object, got %d", len(applied))
+	}
+	return applied[0], nil
+}
+
+// ServerSideApplyManifest applies all the documents of a YAML or JSON
+// manifest with server-side apply and returns the applied objects
+func (k *Kubectl) ServerSideApplyManifest(namespace string, data []byte, opts ApplyOptions) ([]*unstructured.Unstructured, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := decodeObjects(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []*unstructured.Unstructured
+	for _, obj := range objs {
+		a, err := c.apply(namespace, obj, opts.patchOptions())
+		if err != nil {
+			return applied, errors.Wrapf(err, "applying %s %s", obj.GetKind(), obj.GetName())
+		}
+		applied = append(applied, a)
+	}
+	return applied, nil
+}
+
+// Patch patches the resource and returns the patched object. patch can be
+// a string or []byte holding the raw patch, or any value encoded to JSON.
+func (k *Kubectl) Patch(namespace, resource, name string, patchType types.PatchType, patch interface{}, opts PatchOptions) (*unstructured.Unstructured, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.resource(namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch p := patch.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		if data, err = json.Marshal(p); err != nil {
+			return nil, err
+		}
+	}
+
+	patchOptions := metav1.PatchOptions{FieldManager: opts.FieldManager}
+	if patchOptions.FieldManager == "" {
+		patchOptions.FieldManager = FieldManager
+	}
+	if opts.DryRun {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+
+	var subresources []string
+	if opts.Subresource != "" {
+		subresources = append(subresources, opts.Subresource)
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	patched, err := r.Patch(ctx, name, patchType, data, patchOptions, subresources...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "patching %s %s", resource, name)
+	}
+	return patched, nil
+}
+
+// toManifest encodes the object to JSON, YAML or JSON data is kept as is
+func toManifest(obj interface{}) ([]byte, error) {
+	switch o := obj.(type) {
+	case []byte:
+		return o, nil
+	case string:
+		return []byte(o), nil
+	case *unstructured.Unstructured:
+		return o.MarshalJSON()
+	}
+	return json.Marshal(obj)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var _ = Describe("server-side apply and patch", func() {
+	configMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	It("applies typed objects and returns them", func() {
+		k := newFakeKubectl()
+
+		applied, err := k.ServerSideApply("default", configMap("value"), ApplyOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied.GetName()).To(Equal("config"))
+		Expect(applied.Object["data"]).To(HaveKeyWithValue("key", "value"))
+
+		applied, err = k.ServerSideApply("default", configMap("updated"), ApplyOptions{FieldManager: "test", Force: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied.Object["data"]).To(HaveKeyWithValue("key", "updated"))
+	})
+
+	It("applies YAML manifests", func() {
+		k := newFakeKubectl()
+
+		applied, err := k.ServerSideApplyManifest("default", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+`), ApplyOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(HaveLen(2))
+		Expect(k.Exists("default", "configmap", "second")).To(BeTrue())
+
+		_, err = k.ServerSideApply("default", "kind: ConfigMap\napiVersion: v1\nmetadata:\n  name: third\n", ApplyOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(k.Exists("default", "configmap", "third")).To(BeTrue())
+	})
+
+	It("patches any resource", func() {
+		k := newFakeKubectl(configMap("value"))
+
+		patched, err := k.Patch("default", "configmap", "config", MergePatch, map[string]interface{}{
+			"data": map[string]string{"other": "added"},
+		}, PatchOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(patched.Object["data"]).To(HaveKeyWithValue("key", "value"))
+		Expect(patched.Object["data"]).To(HaveKeyWithValue("other", "added"))
+
+		patched, err = k.Patch("default", "configmap", "config", JSONPatch,
+			`[{"op": "replace", "path": "/data/key", "value": "replaced"}]`, PatchOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(patched.Object["data"]).To(HaveKeyWithValue("key", "replaced"))
+
+		patched, err = k.Patch("default", "configmap", "config", MergePatch,
+			[]byte(`{"metadata": {"labels": {"app": "test"}}}`), PatchOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(patched.GetLabels()).To(HaveKeyWithValue("app", "test"))
+
+		_, err = k.Patch("default", "configmap", "missing", MergePatch, `{}`, PatchOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+})
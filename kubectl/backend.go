@@ -0,0 +1,402 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Backend implements the operations of a Kubectl instance, either by
+// running the kubectl binary or with an in-process client-go client
+type Backend interface {
+	// GetPodNames returns the names of the pods matching the selector
+	GetPodNames(namespace, selector string) ([]string, error)
+	// PodStatus returns the status of the pod
+	PodStatus(namespace, podName string) (*PodStatus, error)
+	// Exists returns true if the resource exists, not found is not an error
+	Exists(namespace, resource, name string) (bool, error)
+	// GetData returns the resource formatted with the kubectl output format
+	// (json, yaml or jsonpath=...), not found returns empty data
+	GetData(namespace, resource, name, format string) ([]byte, error)
+	// Apply applies the YAML or JSON manifest
+	Apply(namespace string, data []byte) error
+	// Create creates the resources of the YAML or JSON manifest
+	Create(namespace string, data []byte) error
+	// Delete deletes the resources of the YAML or JSON manifest
+	Delete(namespace string, data []byte) error
+	// DeleteResource deletes the resource, not found is not an error
+	DeleteResource(namespace, resource, name string) error
+	// DeleteLabelFilter deletes the resources matching the selector
+	DeleteLabelFilter(namespace, resource, selector string) error
+	// CreateNamespace creates a namespace
+	CreateNamespace(name string) error
+	// DeleteNamespace deletes a namespace without waiting for it
+	DeleteNamespace(name string) error
+	// PatchNamespace applies a JSON patch to a namespace
+	PatchNamespace(name, patch string) error
+	// CreateSecretFromLiteral creates a generic secret
+	CreateSecretFromLiteral(namespace, name string, values map[string]string) error
+	// CreateServiceAccount creates a service account
+	CreateServiceAccount(namespace, name string) error
+	// CreateRoleBinding binds a cluster role to a service account ("namespace:name")
+	CreateRoleBinding(namespace, clusterrole, serviceaccount, name string) error
+	// GetCRDs returns all CRDs
+	GetCRDs() (*ClusterCrd, error)
+}
+
+// binaryBackend runs the kubectl binary, an empty kubeconfig or context
+// means the kubectl defaults
+type binaryBackend struct {
+	kubeconfig string
+	context    string
+}
+
+// run runs kubectl with the kubeconfig and context of the backend
+func (b *binaryBackend) run(args ...string) ([]byte, error) {
+	return runBinary(kubeCtlCmd, kubectlArgs(b.kubeconfig, b.context, args...)...)
+}
+
+// runWithInput runs kubectl with the given stdin
+func (b *binaryBackend) runWithInput(input []byte, args ...string) ([]byte, error) {
+	return runBinaryWithInput(input, kubeCtlCmd, kubectlArgs(b.kubeconfig, b.context, args...)...)
+}
+
+// GetPodNames returns the names of the pods matching the selector
+func (b *binaryBackend) GetPodNames(namespace, selector string) ([]string, error) {
+	out, err := b.run("--namespace", namespace, "get", "pod",
+		"-l", selector,
+		"-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return []string{}, err
+	}
+
+	return splitNames(string(out)), nil
+}
+
+// PodStatus returns the status of the pod
+func (b *binaryBackend) PodStatus(namespace, podName string) (*PodStatus, error) {
+	out, err := b.run("--namespace", namespace, "get", "pod", podName, "-o", "json")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Getting pod %s failed. %s", podName, string(out))
+	}
+	return decodePodStatus(out)
+}
+
+// Exists returns true if the resource exists
+func (b *binaryBackend) Exists(namespace, resource, name string) (bool, error) {
+	out, err := b.run("--namespace", namespace, "get", resource, name)
+	if err != nil {
+		if isNotFound(string(out)) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "Getting %s %s failed. %s", resource, name, string(out))
+	}
+	return strings.Contains(string(out), name), nil
+}
+
+// GetData returns the resource formatted with the kubectl output format
+func (b *binaryBackend) GetData(namespace, resource, name, format string) ([]byte, error) {
+	out, err := b.run("--namespace", namespace, "get", resource, name, "-o", format)
+	if err != nil {
+		if isNotFound(string(out)) {
+			return []byte{}, nil
+		}
+		return []byte{}, errors.Wrapf(err, "getting  %s failed with template Path %s", name, format)
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+	return []byte{}, errors.Errorf("output is empty for %s with template Path %s", name, format)
+}
+
+// list returns the objects selected by the options, a missing object is
+// not an error
+func (b *binaryBackend) list(namespace, resource string, opts WatchOptions) ([]*unstructured.Unstructured, error) {
+	args := []string{"--namespace", namespace, "get", resource, "-o", "json"}
+	if opts.Name != "" {
+		args = append(args, opts.Name)
+	}
+	if opts.LabelSelector != "" {
+		args = append(args, "--selector", opts.LabelSelector)
+	}
+	out, err := b.run(args...)
+	if err != nil {
+		if isNotFound(string(out)) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "listing %s failed. %s", resource, string(out))
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(out); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", resource)
+	}
+	if !obj.IsList() {
+		return []*unstructured.Unstructured{obj}, nil
+	}
+
+	list, err := obj.ToList()
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*unstructured.Unstructured, 0, len(list.Items))
+	for i := range list.Items {
+		objs = append(objs, &list.Items[i])
+	}
+	return objs, nil
+}
+
+// Apply applies the manifest
+func (b *binaryBackend) Apply(namespace string, data []byte) error {
+	_, err := b.runWithInput(data, namespaceArgs(namespace, "apply", "-f", "-")...)
+	return err
+}
+
+// Create creates the resources of the manifest
+func (b *binaryBackend) Create(namespace string, data []byte) error {
+	_, err := b.runWithInput(data, namespaceArgs(namespace, "create", "-f", "-")...)
+	return err
+}
+
+// Delete deletes the resources of the manifest
+func (b *binaryBackend) Delete(namespace string, data []byte) error {
+	_, err := b.runWithInput(data, namespaceArgs(namespace, "delete", "-f", "-")...)
+	return err
+}
+
+// DeleteResource deletes the resource
+func (b *binaryBackend) DeleteResource(namespace, resource, name string) error {
+	out, err := b.run("--namespace", namespace, "delete", resource, name)
+	if err != nil {
+		if isNotFound(string(out)) {
+			return nil
+		}
+		return errors.Wrapf(err, "deleting resource %s failed %s", resource, string(out))
+	}
+	return nil
+}
+
+// DeleteLabelFilter deletes the resources matching the selector
+func (b *binaryBackend) DeleteLabelFilter(namespace, resource, selector string) error {
+	_, err := b.run("--namespace", namespace, "delete", resource, "-l", selector)
+	if err != nil {
+		return errors.Wrapf(err, "deleting resource %s with label %s failed", resource, selector)
+	}
+	return nil
+}
+
+// CreateNamespace creates a namespace
+func (b *binaryBackend) CreateNamespace(name string) error {
+	_, err := b.run("create", "namespace", name)
+	if err != nil {
+		return errors.Wrapf(err, "Creating namespace %s failed", name)
+	}
+	return nil
+}
+
+// DeleteNamespace deletes a namespace without waiting for it
+func (b *binaryBackend) DeleteNamespace(name string) error {
+	_, err := b.run("delete", "--wait=false", "--ignore-not-found", "--grace-period=30", "namespace", name)
+	if err != nil {
+		return errors.Wrapf(err, "Deleting namespace %s failed", name)
+	}
+	return nil
+}
+
+// PatchNamespace applies a JSON patch to a namespace
+func (b *binaryBackend) PatchNamespace(name, patch string) error {
+	_, err := b.run("patch", "namespace", name, "--type=json", "-p", patch)
+	return err
+}
+
+// CreateSecretFromLiteral creates a generic secret
+func (b *binaryBackend) CreateSecretFromLiteral(namespace, name string, values map[string]string) error {
+	args := []string{"--namespace", namespace, "create", "secret", "generic", name}
+	for key, value := range values {
+		args = append(args, fmt.Sprintf("--from-literal=%s=%s", key, value))
+	}
+
+	_, err := b.run(args...)
+	if err != nil {
+		return errors.Wrapf(err, "creating secret %s failed from literal value", name)
+	}
+	return nil
+}
+
+// CreateServiceAccount creates a service account
+func (b *binaryBackend) CreateServiceAccount(namespace, name string) error {
+	out, err := b.run("--namespace", namespace, "create", "serviceaccount", name)
+	if err != nil {
+		return errors.Wrapf(err, "Kubectl create serviceaccount with %s failed. %s", name, string(out))
+	}
+	return nil
+}
+
+// CreateRoleBinding binds a cluster role to a service account
+func (b *binaryBackend) CreateRoleBinding(namespace, clusterrole, serviceaccount, name string) error {
+	out, err := b.run("--namespace", namespace, "create", "rolebinding", "--clusterrole", clusterrole, "--serviceaccount", serviceaccount, name)
+	if err != nil {
+		return errors.Wrapf(err, "Kubectl create rolebinding failed with role %s failed. %s", clusterrole, string(out))
+	}
+	return nil
+}
+
+// GetCRDs returns all CRDs
+func (b *binaryBackend) GetCRDs() (*ClusterCrd, error) {
+	customResource := &ClusterCrd{}
+	out, err := b.run("get", "crds", "-o=json")
+	if err != nil {
+		return customResource, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(out))
+	if err := d.Decode(customResource); err != nil {
+		return customResource, err
+	}
+	return customResource, nil
+}
+
+// runBinaryWithInput executes a binary cmd with the given stdin and returns the stdOutput and stdError combined
+func runBinaryWithInput(input []byte, binaryName string, args ...string) ([]byte, error) {
+	cmd := exec.Command(binaryName, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	stdOutput, err := cmd.CombinedOutput()
+	if err != nil {
+		return stdOutput, errors.Wrapf(err, "%s cmd, failed with the following error: %s", cmd.Args, string(stdOutput))
+	}
+	return stdOutput, nil
+}
+
+// kubectlArgs prepends the kubeconfig and context flags if given
+func kubectlArgs(kubeconfig, context string, args ...string) []string {
+	var flags []string
+	if kubeconfig != "" {
+		flags = append(flags, "--kubeconfig", kubeconfig)
+	}
+	if context != "" {
+		flags = append(flags, "--context", context)
+	}
+	return append(flags, args...)
+}
+
+// namespaceArgs prepends the namespace flag if a namespace is given
+func namespaceArgs(namespace string, args ...string) []string {
+	if namespace == "" {
+		return args
+	}
+	return append([]string{"--namespace", namespace}, args...)
+}
+
+// isNotFound returns true if the kubectl output is a NotFound error
+func isNotFound(out string) bool {
+	return strings.Contains(out, "Error from server (NotFound)") || strings.Contains(out, "no matching resources found")
+}
+
+// splitNames returns the non empty names of a space separated list
+func splitNames(out string) []string {
+	var names []string
+	for _, n := range strings.Split(out, " ") {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// decodePodStatus extracts the status of a JSON encoded pod
+func decodePodStatus(data []byte) (*PodStatus, error) {
+	var pod Pod
+	if err := json.Unmarshal(data, &pod); err != nil {
+		return nil, errors.Wrapf(err, "Invalid json '%s': %s", string(data), err.Error())
+	}
+	return &pod.Status, nil
+}
+
+// readManifest reads a manifest file, URL, "-" for the standard input, or
+// the YAML and JSON files of a directory as a single multi-document manifest
+func readManifest(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return downloadManifest(path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs [][]byte
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if e.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, data)
+	}
+	if len(docs) == 0 {
+		return nil, errors.Errorf("no manifest found in %s", path)
+	}
+
+	return bytes.Join(docs, []byte("\n---\n")), nil
+}
+
+// downloadManifest downloads the manifest of the URL
+func downloadManifest(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("downloading %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// shellQuote quotes an argument for bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
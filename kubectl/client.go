@@ -0,0 +1,653 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// FieldManager is the field manager used for server-side apply
+const FieldManager = "ele-testhelpers"
+
+// ClientBackend runs the Kubectl operations with in-process client-go clients
+type ClientBackend struct {
+	Config    *rest.Config
+	Clientset kubernetes.Interface
+	Dynamic   dynamic.Interface
+	Mapper    meta.RESTMapper
+	Timeout   time.Duration
+	// NewExecutor creates the executors of Exec, e.g. to test without an
+	// API server, websockets with a SPDY fallback are used if nil
+	NewExecutor ExecutorFunc
+	// NewDialer creates the dialers of PortForward, SPDY is used if nil
+	NewDialer DialerFunc
+	// ApplyOptions configures Apply, fields owned by other managers are
+	// only taken over if Force is set
+	ApplyOptions ApplyOptions
+}
+
+// NewClientBackend returns a client-go backend for the given REST config
+func NewClientBackend(config *rest.Config) (*ClientBackend, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shortcuts allow using 'svc', 'ns' and friends like with kubectl
+	cached := memory.NewMemCacheClient(clientset.Discovery())
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil)
+
+	b := NewClientBackendFromClients(clientset, dyn, mapper)
+	b.Config = config
+	return b, nil
+}
+
+// NewClientBackendFromClients returns a client-go backend using existing
+// clients, e.g. fake clientsets
+func NewClientBackendFromClients(clientset kubernetes.Interface, dyn dynamic.Interface, mapper meta.RESTMapper) *ClientBackend {
+	return &ClientBackend{
+		Clientset: clientset,
+		Dynamic:   dyn,
+		Mapper:    mapper,
+		Timeout:   60 * time.Second,
+	}
+}
+
+// NewForConfig returns a Kubectl using a client-go backend
+func NewForConfig(config *rest.Config) (*Kubectl, error) {
+	b, err := NewClientBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	k := New()
+	k.Backend = b
+	return k, nil
+}
+
+// NewForClients returns a Kubectl using a client-go backend built on the given clients
+func NewForClients(clientset kubernetes.Interface, dyn dynamic.Interface, mapper meta.RESTMapper) *Kubectl {
+	k := New()
+	k.Backend = NewClientBackendFromClients(clientset, dyn, mapper)
+	return k
+}
+
+func (c *ClientBackend) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.Timeout)
+}
+
+// mapping resolves a resource as accepted by kubectl ('pods', 'svc',
+// 'deployment.apps', 'cluster.v1.provisioning.cattle.io'...). The cached
+// discovery is refreshed once for unknown resources, e.g. CRDs installed by
+// an operator since the first lookup.
+func (c *ClientBackend) mapping(resource string) (*meta.RESTMapping, error) {
+	m, err := c.lookupMapping(resource)
+	if meta.IsNoMatchError(err) {
+		c.resetMapper()
+		m, err = c.lookupMapping(resource)
+	}
+	return m, err
+}
+
+// restMapping returns the mapping of the kind, refreshing the cached
+// discovery once if the kind is unknown
+func (c *ClientBackend) restMapping(gk schema.GroupKind, version string) (*meta.RESTMapping, error) {
+	m, err := c.Mapper.RESTMapping(gk, version)
+	if meta.IsNoMatchError(err) {
+		c.resetMapper()
+		m, err = c.Mapper.RESTMapping(gk, version)
+	}
+	return m, err
+}
+
+// lookupMapping resolves the resource with the current discovery
+func (c *ClientBackend) lookupMapping(resource string) (*meta.RESTMapping, error) {
+	var gvk schema.GroupVersionKind
+
+	fullySpecified, groupResource := schema.ParseResourceArg(strings.ToLower(resource))
+	if fullySpecified != nil {
+		if k, err := c.Mapper.KindFor(*fullySpecified); err == nil {
+			gvk = k
+		}
+	}
+	if gvk.Empty() {
+		k, err := c.Mapper.KindFor(groupResource.WithVersion(""))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unknown resource %s", resource)
+		}
+		gvk = k
+	}
+
+	return c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// resource returns the dynamic client for the resource in the namespace
+func (c *ClientBackend) resource(namespace, resource string) (dynamic.ResourceInterface, error) {
+	m, err := c.mapping(resource)
+	if err != nil {
+		return nil, err
+	}
+	return c.resourceForMapping(namespace, m), nil
+}
+
+func (c *ClientBackend) resourceForMapping(namespace string, m *meta.RESTMapping) dynamic.ResourceInterface {
+	if m.Scope.Name() == meta.RESTScopeNameNamespace {
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		return c.Dynamic.Resource(m.Resource).Namespace(namespace)
+	}
+	return c.Dynamic.Resource(m.Resource)
+}
+
+// objectResource returns the dynamic client for the object
+func (c *ClientBackend) objectResource(namespace string, obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	m, err := c.restMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown kind %s", gvk)
+	}
+	if obj.GetNamespace() != "" {
+		namespace = obj.GetNamespace()
+	}
+	return c.resourceForMapping(namespace, m), nil
+}
+
+// GetPodNames returns the names of the pods matching the selector
+func (c *ClientBackend) GetPodNames(namespace, selector string) ([]string, error) {
+	r, err := c.resource(namespace, "pods")
+	if err != nil {
+		return []string{}, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	pods, err := r.List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return []string{}, err
+	}
+
+	var names []string
+	for _, p := range pods.Items {
+		names = append(names, p.GetName())
+	}
+	return names, nil
+}
+
+// PodStatus returns the status of the pod
+func (c *ClientBackend) PodStatus(namespace, podName string) (*PodStatus, error) {
+	pod, err := c.get(namespace, "pods", podName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Getting pod %s failed", podName)
+	}
+
+	data, err := pod.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return decodePodStatus(data)
+}
+
+// Exists returns true if the resource exists
+func (c *ClientBackend) Exists(namespace, resource, name string) (bool, error) {
+	_, err := c.get(namespace, resource, name)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "Getting %s %s failed", resource, name)
+	}
+	return true, nil
+}
+
+func (c *ClientBackend) get(namespace, resource, name string) (*unstructured.Unstructured, error) {
+	r, err := c.resource(namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	return r.Get(ctx, name, metav1.GetOptions{})
+}
+
+// GetData returns the resource formatted with the kubectl output format,
+// only json, yaml, jsonpath and go-template are supported
+func (c *ClientBackend) GetData(namespace, resource, name, format string) ([]byte, error) {
+	obj, err := c.get(namespace, resource, name)
+	if apierrors.IsNotFound(err) {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return []byte{}, errors.Wrapf(err, "getting %s failed", name)
+	}
+
+	out, err := formatObject(obj.Object, format)
+	if err != nil {
+		return []byte{}, err
+	}
+	if len(out) == 0 {
+		return []byte{}, errors.Errorf("output is empty for %s with template Path %s", name, format)
+	}
+	return out, nil
+}
+
+// formatObject renders an object with a kubectl output format
+func formatObject(obj interface{}, format string) ([]byte, error) {
+	switch {
+	case format == "json":
+		return json.MarshalIndent(obj, "", "    ")
+	case format == "yaml":
+		return yaml.Marshal(obj)
+	case strings.HasPrefix(format, "jsonpath="):
+		tmpl := strings.TrimPrefix(format, "jsonpath=")
+		jp := jsonpath.New("output").AllowMissingKeys(true)
+		if err := jp.Parse(tmpl); err != nil {
+			return nil, errors.Wrapf(err, "invalid jsonpath %s", tmpl)
+		}
+
+		var buf bytes.Buffer
+		if err := jp.Execute(&buf, obj); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case strings.HasPrefix(format, "go-template="):
+		tmpl := strings.TrimPrefix(format, "go-template=")
+		t, err := template.New("output").Funcs(template.FuncMap{
+			"base64decode": func(s string) (string, error) {
+				data, err := base64.StdEncoding.DecodeString(s)
+				return string(data), err
+			},
+		}).Parse(tmpl)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid go-template %s", tmpl)
+		}
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, obj); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	return nil, errors.Errorf("output format %s is not supported by the client-go backend", format)
+}
+
+// Apply applies the manifest with server-side apply
+func (c *ClientBackend) Apply(namespace string, data []byte) error {
+	objs, err := decodeObjects(data)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		if _, err := c.apply(namespace, obj, c.ApplyOptions.patchOptions()); err != nil {
+			return errors.Wrapf(err, "Applying resource %s", obj.GetName())
+		}
+	}
+	return nil
+}
+
+// apply server-side applies one object
+func (c *ClientBackend) apply(namespace string, obj *unstructured.Unstructured, opts metav1.PatchOptions) (*unstructured.Unstructured, error) {
+	r, err := c.objectResource(namespace, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	return r.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, opts)
+}
+
+// Create creates the resources of the manifest
+func (c *ClientBackend) Create(namespace string, data []byte) error {
+	objs, err := decodeObjects(data)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		r, err := c.objectResource(namespace, obj)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := c.context()
+		_, err = r.Create(ctx, obj, metav1.CreateOptions{FieldManager: FieldManager})
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "creating %s %s failed", obj.GetKind(), obj.GetName())
+		}
+	}
+	return nil
+}
+
+// Delete deletes the resources of the manifest, in reverse order
+func (c *ClientBackend) Delete(namespace string, data []byte) error {
+	objs, err := decodeObjects(data)
+	if err != nil {
+		return err
+	}
+
+	for i := len(objs) - 1; i >= 0; i-- {
+		r, err := c.objectResource(namespace, objs[i])
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := c.context()
+		err = r.Delete(ctx, objs[i].GetName(), metav1.DeleteOptions{})
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "deleting %s %s failed", objs[i].GetKind(), objs[i].GetName())
+		}
+	}
+	return nil
+}
+
+// DeleteResource deletes the resource
+func (c *ClientBackend) DeleteResource(namespace, resource, name string) error {
+	r, err := c.resource(namespace, resource)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	err = r.Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "deleting resource %s failed", resource)
+	}
+	return nil
+}
+
+// DeleteLabelFilter deletes the resources matching the selector
+func (c *ClientBackend) DeleteLabelFilter(namespace, resource, selector string) error {
+	r, err := c.resource(namespace, resource)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	list, err := r.List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return errors.Wrapf(err, "deleting resource %s with label %s failed", resource, selector)
+	}
+	for _, item := range list.Items {
+		if err := r.Delete(ctx, item.GetName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "deleting resource %s with label %s failed", resource, selector)
+		}
+	}
+	return nil
+}
+
+// CreateNamespace creates a namespace
+func (c *ClientBackend) CreateNamespace(name string) error {
+	ns := &corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+	if err := c.createObject("", ns); err != nil {
+		return errors.Wrapf(err, "Creating namespace %s failed", name)
+	}
+	return nil
+}
+
+// DeleteNamespace deletes a namespace without waiting for it
+func (c *ClientBackend) DeleteNamespace(name string) error {
+	r, err := c.resource("", "namespaces")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	err = r.Delete(ctx, name, metav1.DeleteOptions{GracePeriodSeconds: ptr(int64(30))})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "Deleting namespace %s failed", name)
+	}
+	return nil
+}
+
+// PatchNamespace applies a JSON patch to a namespace
+func (c *ClientBackend) PatchNamespace(name, patch string) error {
+	r, err := c.resource("", "namespaces")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	_, err = r.Patch(ctx, name, types.JSONPatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// CreateSecretFromLiteral creates a generic secret
+func (c *ClientBackend) CreateSecretFromLiteral(namespace, name string, values map[string]string) error {
+	// StringData is only converted by the API server, encode it here
+	data := map[string][]byte{}
+	for key, value := range values {
+		data[key] = []byte(value)
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}
+	if err := c.createObject(namespace, secret); err != nil {
+		return errors.Wrapf(err, "creating secret %s failed from literal value", name)
+	}
+	return nil
+}
+
+// CreateServiceAccount creates a service account
+func (c *ClientBackend) CreateServiceAccount(namespace, name string) error {
+	sa := &corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+	if err := c.createObject(namespace, sa); err != nil {
+		return errors.Wrapf(err, "creating serviceaccount %s failed", name)
+	}
+	return nil
+}
+
+// CreateRoleBinding binds a cluster role to a service account ("namespace:name")
+func (c *ClientBackend) CreateRoleBinding(namespace, clusterrole, serviceaccount, name string) error {
+	saNamespace, saName, found := strings.Cut(serviceaccount, ":")
+	if !found {
+		return errors.Errorf("serviceaccount must be namespace:name, got %s", serviceaccount)
+	}
+
+	rb := &rbacv1.RoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterrole,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      saName,
+			Namespace: saNamespace,
+		}},
+	}
+	if err := c.createObject(namespace, rb); err != nil {
+		return errors.Wrapf(err, "creating rolebinding %s failed with role %s", name, clusterrole)
+	}
+	return nil
+}
+
+// GetCRDs returns all CRDs
+func (c *ClientBackend) GetCRDs() (*ClusterCrd, error) {
+	customResource := &ClusterCrd{}
+
+	r, err := c.resource("", "customresourcedefinitions.apiextensions.k8s.io")
+	if err != nil {
+		return customResource, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	list, err := r.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return customResource, err
+	}
+
+	data, err := list.MarshalJSON()
+	if err != nil {
+		return customResource, err
+	}
+	err = json.Unmarshal(data, customResource)
+	return customResource, err
+}
+
+// createObject creates a typed object, its TypeMeta must be set
+func (c *ClientBackend) createObject(namespace string, obj interface{}) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	r, err := c.objectResource(namespace, u)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	_, err = r.Create(ctx, u, metav1.CreateOptions{FieldManager: FieldManager})
+	return err
+}
+
+// decodeObjects decodes a YAML or JSON manifest, which may contain several
+// documents and lists
+func decodeObjects(data []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "decoding manifest")
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		if obj.IsList() {
+			err := obj.EachListItem(func(o runtime.Object) error {
+				item, ok := o.(*unstructured.Unstructured)
+				if !ok {
+					return fmt.Errorf("unexpected list item %T", o)
+				}
+				objs = append(objs, item)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// client returns the client-go backend of the instance, it is created from
+// the kubeconfig and context when another backend is used
+func (k *Kubectl) client() (*ClientBackend, error) {
+	if c, ok := k.Backend.(*ClientBackend); ok {
+		return c, nil
+	}
+
+	k.clientMu.Lock()
+	defer k.clientMu.Unlock()
+
+	if k.clientBackend == nil {
+		config, err := k.RESTConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "loading kubeconfig")
+		}
+
+		c, err := NewClientBackend(config)
+		if err != nil {
+			return nil, err
+		}
+		k.clientBackend = c
+	}
+	return k.clientBackend, nil
+}
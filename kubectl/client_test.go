@@ -0,0 +1,416 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery/cached/memory"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// machineRegistrationKind is a custom resource known by the fake clients
+var machineRegistrationKind = schema.GroupVersionKind{Group: "elemental.cattle.io", Version: "v1beta1", Kind: "MachineRegistration"}
+
+// machineInventorySelectorKind is a kind served only in a version which is
+// not the preferred one of its group
+var machineInventorySelectorKind = schema.GroupVersionKind{Group: "elemental.cattle.io", Version: "v1alpha1", Kind: "MachineInventorySelector"}
+
+// crdKind is the kind of the CustomResourceDefinitions, the fake clientset
+// does not know the apiextensions types
+var crdKind = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// fakeMapper knows the resources used by the tests
+func fakeMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, kind := range []string{"Pod", "Service", "Secret", "ConfigMap", "ServiceAccount", "PersistentVolumeClaim"} {
+		mapper.Add(corev1.SchemeGroupVersion.WithKind(kind), meta.RESTScopeNamespace)
+	}
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	for _, kind := range []string{"DaemonSet", "StatefulSet", "ReplicaSet"} {
+		mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind}, meta.RESTScopeNamespace)
+	}
+	for _, kind := range []string{"Job", "CronJob"} {
+		mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: kind}, meta.RESTScopeNamespace)
+	}
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Event"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+	mapper.Add(machineRegistrationKind, meta.RESTScopeNamespace)
+	mapper.Add(machineInventorySelectorKind, meta.RESTScopeNamespace)
+	mapper.Add(crdKind, meta.RESTScopeRoot)
+	for _, kind := range []string{"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"} {
+		mapper.Add(schema.GroupVersionKind{Group: "admissionregistration.k8s.io", Version: "v1", Kind: kind}, meta.RESTScopeRoot)
+	}
+	mapper.Add(schema.GroupVersionKind{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"}, meta.RESTScopeNamespace)
+	return mapper
+}
+
+// newFakeKubectl returns a Kubectl using fake clients seeded with objs, the
+// dynamic client uses a field managed tracker to support server-side apply
+func newFakeKubectl(objs ...runtime.Object) *Kubectl {
+	unstructuredScheme := runtime.NewScheme()
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if strings.HasSuffix(gvk.Kind, "List") {
+			unstructuredScheme.AddKnownTypeWithName(gvk, &unstructured.UnstructuredList{})
+			continue
+		}
+		unstructuredScheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	}
+	unstructuredScheme.AddKnownTypeWithName(machineRegistrationKind, &unstructured.Unstructured{})
+	unstructuredScheme.AddKnownTypeWithName(machineRegistrationKind.GroupVersion().WithKind("MachineRegistrationList"), &unstructured.UnstructuredList{})
+	unstructuredScheme.AddKnownTypeWithName(machineInventorySelectorKind, &unstructured.Unstructured{})
+	unstructuredScheme.AddKnownTypeWithName(machineInventorySelectorKind.GroupVersion().WithKind("MachineInventorySelectorList"), &unstructured.UnstructuredList{})
+	unstructuredScheme.AddKnownTypeWithName(crdKind, &unstructured.Unstructured{})
+	unstructuredScheme.AddKnownTypeWithName(crdKind.GroupVersion().WithKind("CustomResourceDefinitionList"), &unstructured.UnstructuredList{})
+
+	tracker := k8stesting.NewFieldManagedObjectTracker(unstructuredScheme,
+		serializer.NewCodecFactory(unstructuredScheme).UniversalDecoder(),
+		managedfields.NewDeducedTypeConverter())
+	for _, obj := range objs {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tracker.Add(&unstructured.Unstructured{Object: content})).To(Succeed())
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClient(unstructuredScheme)
+	dyn.PrependReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	dyn.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := tracker.Watch(action.GetResource(), action.GetNamespace())
+		return true, w, err
+	})
+
+	k := NewForClients(fake.NewClientset(), dyn, fakeMapper())
+	k.PollTimeout = 2 * time.Second
+	k.PollInterval = 10 * time.Millisecond
+	return k
+}
+
+func readyPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "test"}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:    "test",
+				Ready:   true,
+				Started: &[]bool{true}[0],
+				State:   corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		},
+	}
+}
+
+var _ = Describe("client-go backend", func() {
+	It("lists and inspects pods", func() {
+		k := newFakeKubectl(readyPod("test-1"), readyPod("test-2"))
+
+		names, err := k.GetPodNames("default", "app=test")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names).To(ConsistOf("test-1", "test-2"))
+
+		names, err = k.GetPodNames("default", "app=other")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names).To(BeEmpty())
+
+		status, err := k.PodStatus("default", "test-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status.Phase).To(Equal("Running"))
+		Expect(status.ContainerStatuses).To(HaveLen(1))
+
+		Expect(k.WaitForNamespaceWithPod("default", "app=test")).To(Succeed())
+	})
+
+	It("checks whether resources exist", func() {
+		k := newFakeKubectl(readyPod("test-1"))
+
+		Expect(k.Exists("default", "pod", "test-1")).To(BeTrue())
+		Expect(k.Exists("default", "pods", "missing")).To(BeFalse())
+		Expect(k.SecretExists("default", "missing")).To(BeFalse())
+
+		_, err := k.Exists("default", "unknown", "test-1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("discovers resources added after the first lookup", func() {
+		k := newFakeKubectl(readyPod("test-1"))
+		b := k.Backend.(*ClientBackend)
+		cs := b.Clientset.(*fake.Clientset)
+		cs.Resources = []*metav1.APIResourceList{{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true}},
+		}}
+		b.Mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(cs.Discovery()))
+
+		Expect(k.Exists("default", "pods", "test-1")).To(BeTrue())
+
+		// The CRD is installed out of band, e.g. by helm
+		cs.Resources = append(cs.Resources, &metav1.APIResourceList{
+			GroupVersion: machineRegistrationKind.GroupVersion().String(),
+			APIResources: []metav1.APIResource{{Name: "machineregistrations", Kind: "MachineRegistration", Namespaced: true}},
+		})
+		Expect(k.Exists("default", "machineregistrations", "missing")).To(BeFalse())
+	})
+
+	It("applies manifests and reads them back", func() {
+		k := newFakeKubectl()
+
+		cm := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "config"},
+			"data":       map[string]string{"key": "value"},
+		}
+		Expect(k.ApplyYAML("default", "config", cm)).To(Succeed())
+
+		c, err := k.GetConfigMap("default", "config")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Data).To(HaveKeyWithValue("key", "value"))
+
+		cm["data"] = map[string]string{"key": "updated"}
+		Expect(k.ApplyJSON("default", "config", cm)).To(Succeed())
+		Expect(k.WaitForData("default", "configmap", "config", "jsonpath={.data.key}", "updated")).To(Succeed())
+
+		data, err := k.Backend.GetData("default", "configmap", "missing", "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(BeEmpty())
+	})
+
+	It("applies multi-document manifests", func() {
+		k := newFakeKubectl()
+
+		manifest := `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+  namespace: test
+`
+		Expect(k.Backend.Apply("", []byte(manifest))).To(Succeed())
+		Expect(k.Exists("", "namespace", "test")).To(BeTrue())
+		Expect(k.ServiceExists("test", "svc")).To(BeTrue())
+	})
+
+	It("creates and deletes RBAC resources", func() {
+		k := newFakeKubectl()
+
+		Expect(k.CreateServiceAccount("default", "sa")).To(Succeed())
+		Expect(k.CreateRoleBinding("default", "admin", "default:sa", "rb")).To(Succeed())
+		Expect(k.Exists("default", "serviceaccount", "sa")).To(BeTrue())
+
+		data, err := k.Backend.GetData("default", "rolebinding.rbac.authorization.k8s.io", "rb", "jsonpath={.subjects[0].name}")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("sa"))
+
+		Expect(k.DeleteRoleBinding("default", "rb")).To(Succeed())
+		Expect(k.DeleteServiceAccount("default", "sa")).To(Succeed())
+		Expect(k.Exists("default", "serviceaccount", "sa")).To(BeFalse())
+
+		Expect(k.CreateRoleBinding("default", "admin", "sa", "rb")).ToNot(Succeed())
+	})
+
+	It("manages namespaces and secrets", func() {
+		k := newFakeKubectl()
+
+		Expect(k.Backend.CreateNamespace("test")).To(Succeed())
+		Expect(k.Backend.CreateSecretFromLiteral("test", "secret", map[string]string{"user": "admin"})).To(Succeed())
+		Expect(k.SecretExists("test", "secret")).To(BeTrue())
+
+		data, err := k.Backend.GetData("test", "secret", "secret", "jsonpath={.data.user}")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("YWRtaW4="))
+
+		Expect(k.Backend.DeleteNamespace("test")).To(Succeed())
+		Expect(k.WaitForNamespaceDelete("test")).To(Succeed())
+		Expect(k.Backend.DeleteNamespace("test")).To(Succeed())
+	})
+
+	It("deletes resources by label", func() {
+		k := newFakeKubectl(readyPod("test-1"), readyPod("test-2"))
+
+		Expect(k.Backend.DeleteLabelFilter("default", "pod", "app=test")).To(Succeed())
+		Expect(k.WaitForPodDelete("default", "test-1")).To(Succeed())
+		Expect(k.WaitNamespacePodsDelete("default")).To(Succeed())
+	})
+
+	It("rejects unsupported output formats", func() {
+		k := newFakeKubectl(readyPod("test-1"))
+
+		_, err := k.Backend.GetData("default", "pod", "test-1", "wide")
+		Expect(err).To(HaveOccurred())
+
+		data, err := k.Backend.GetData("default", "pod", "test-1", "yaml")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("name: test-1"))
+	})
+
+	It("applies and deletes manifest directories", func() {
+		k := newFakeKubectl()
+
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "01-cm.yaml"), []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+`), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "02-svc.yaml"), []byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+`), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "README"), []byte("not a manifest"), 0644)).To(Succeed())
+
+		Expect(k.ApplyFile("default", dir)).To(Succeed())
+		Expect(k.Exists("default", "configmap", "config")).To(BeTrue())
+		Expect(k.ServiceExists("default", "svc")).To(BeTrue())
+
+		Expect(k.DeleteFile("default", dir)).To(Succeed())
+		Expect(k.Exists("default", "configmap", "config")).To(BeFalse())
+		Expect(k.ServiceExists("default", "svc")).To(BeFalse())
+
+		Expect(k.ApplyFile("default", filepath.Join(dir, "missing.yaml"))).ToNot(Succeed())
+	})
+
+	It("applies manifests from URLs", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/config.yaml" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"))
+		}))
+		defer server.Close()
+
+		k := newFakeKubectl()
+		Expect(k.ApplyFile("default", server.URL+"/config.yaml")).To(Succeed())
+		Expect(k.Exists("default", "configmap", "config")).To(BeTrue())
+		Expect(k.ApplyFile("default", server.URL+"/missing.yaml")).To(MatchError(ContainSubstring("404 Not Found")))
+	})
+
+	It("supports go-template output", func() {
+		k := newFakeKubectl()
+
+		Expect(k.CreateSecretFromLiteral("default", "secret", map[string]string{"user": "admin"})).To(Succeed())
+		data, err := k.GetData("default", "secret", "secret", "go-template={{.data.user | base64decode}}")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("admin"))
+		Expect(k.SecretCheckData("default", "secret", ".data.user")).To(Succeed())
+	})
+})
+
+var _ = Describe("kubectl binary backend", func() {
+	var calls string
+
+	BeforeEach(func() {
+		// The fake kubectl records and prints its arguments
+		dir := GinkgoT().TempDir()
+		calls = filepath.Join(dir, "calls")
+		script := "#!/bin/sh\necho \"$@\" >> " + calls + "\necho \"$@\"\n"
+		Expect(os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755)).To(Succeed())
+		GinkgoT().Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	})
+
+	It("passes manifest paths to kubectl as is", func() {
+		k := New()
+		Expect(k.ApplyFile("default", "https://github.com/rancher/elemental-operator/releases/download/v1.7.0/crds.yaml")).To(Succeed())
+		Expect(k.DeleteFile("", "manifests")).To(Succeed())
+
+		data, err := os.ReadFile(calls)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("--namespace default apply -f https://github.com/rancher/elemental-operator/releases/download/v1.7.0/crds.yaml\ndelete -f manifests\n"))
+	})
+
+	It("runs commands with the arguments of the caller", func() {
+		k := New()
+		out, err := k.RunCommandWithOutput("default", "test-1", "-c test -- sh -c 'ls /tmp | wc -l'")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("--namespace default exec test-1 -c test -- sh -c ls /tmp | wc -l\n"))
+	})
+})
+
+var _ = Describe("Kubectl configuration", func() {
+	It("selects the kubeconfig and context of the instance", func() {
+		kubeconfig := filepath.Join(GinkgoT().TempDir(), "kubeconfig")
+		Expect(os.WriteFile(kubeconfig, []byte(`
+apiVersion: v1
+kind: Config
+clusters:
+- name: local
+  cluster:
+    server: https://local.example.com:6443
+- name: downstream
+  cluster:
+    server: https://downstream.example.com:6443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: local
+  context:
+    cluster: local
+    user: admin
+- name: downstream
+  context:
+    cluster: downstream
+    user: admin
+current-context: local
+`), 0600)).To(Succeed())
+
+		cfg, err := NewForKubeconfig(kubeconfig, "").RESTConfig()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Host).To(Equal("https://local.example.com:6443"))
+
+		cfg, err = NewForKubeconfig(kubeconfig, "downstream").RESTConfig()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Host).To(Equal("https://downstream.example.com:6443"))
+
+		_, err = NewForKubeconfig(kubeconfig, "missing").RESTConfig()
+		Expect(err).To(HaveOccurred())
+	})
+})
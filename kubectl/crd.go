@@ -0,0 +1,274 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	wait "github.com/rancher-sandbox/ele-testhelpers/helpers"
+)
+
+// crdResource is the resource of the CustomResourceDefinitions
+const crdResource = "customresourcedefinitions.apiextensions.k8s.io"
+
+// CRDVersion is a version of a CRD
+type CRDVersion struct {
+	Name    string
+	Served  bool
+	Storage bool
+}
+
+// CRD describes a CustomResourceDefinition
+type CRD struct {
+	Name     string
+	Group    string
+	Kind     string
+	Plural   string
+	Versions []CRDVersion
+	// Established is true once the API server serves the resource
+	Established bool
+	// NamesAccepted is false if the names conflict with another CRD
+	NamesAccepted bool
+}
+
+// Serves returns true if the version is served
+func (c *CRD) Serves(version string) bool {
+	for _, v := range c.Versions {
+		if v.Name == version {
+			return v.Served
+		}
+	}
+	return false
+}
+
+// StorageVersion returns the version used to store the objects
+func (c *CRD) StorageVersion() string {
+	for _, v := range c.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// GetCRD returns the CRD, e.g. machineregistrations.elemental.cattle.io
+func (k *Kubectl) GetCRD(name string) (*CRD, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := c.get("", crdResource, name)
+	if err != nil {
+		return nil, err
+	}
+	return crdOf(obj), nil
+}
+
+// crdOf decodes the fields of the CRD
+func crdOf(obj *unstructured.Unstructured) *CRD {
+	crd := &CRD{Name: obj.GetName()}
+	crd.Group, _, _ = unstructured.NestedString(obj.Object, "spec", "group")
+	crd.Kind, _, _ = unstructured.NestedString(obj.Object, "spec", "names", "kind")
+	crd.Plural, _, _ = unstructured.NestedString(obj.Object, "spec", "names", "plural")
+
+	versions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		served, _, _ := unstructured.NestedBool(version, "served")
+		storage, _, _ := unstructured.NestedBool(version, "storage")
+		crd.Versions = append(crd.Versions, CRDVersion{Name: name, Served: served, Storage: storage})
+	}
+
+	established, _ := conditionStatus(obj, "Established")
+	accepted, _ := conditionStatus(obj, "NamesAccepted")
+	crd.Established = established == "True"
+	crd.NamesAccepted = accepted == "True"
+	return crd
+}
+
+// crdEstablished matches the established CRDs
+type crdEstablished struct{}
+
+// CRDEstablished matches CRDs which are Established and whose names are
+// accepted, like kubectl wait --for=condition=Established. A CRD whose
+// names are rejected stops the wait.
+func CRDEstablished() WaitCondition {
+	return &crdEstablished{}
+}
+
+func (c *crdEstablished) Match(obj *unstructured.Unstructured) (bool, error) {
+	if status, found := conditionStatus(obj, "NamesAccepted"); found && status == "False" {
+		return false, errors.Errorf("names of %s not accepted: %s", obj.GetName(), conditionMessage(obj, "NamesAccepted"))
+	}
+	crd := crdOf(obj)
+	return crd.Established && crd.NamesAccepted, nil
+}
+
+func (c *crdEstablished) Observe(obj *unstructured.Unstructured) string {
+	crd := crdOf(obj)
+	return fmt.Sprintf("Established=%t NamesAccepted=%t", crd.Established, crd.NamesAccepted)
+}
+
+func (c *crdEstablished) String() string {
+	return "CRD established"
+}
+
+// conditionMessage returns the reason and message of the condition
+func conditionMessage(obj *unstructured.Unstructured, conditionType string) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != conditionType {
+			continue
+		}
+		reason, _ := cond["reason"].(string)
+		message, _ := cond["message"].(string)
+		return reason + " " + message
+	}
+	return ""
+}
+
+// WaitForCRDs waits for the CRDs to be Established with their names
+// accepted, and for the API discovery to expose their served versions, so
+// their custom resources can be applied right away
+func (k *Kubectl) WaitForCRDs(names ...string) error {
+	for _, name := range names {
+		if err := k.WaitUntil("", crdResource, WatchOptions{Name: name}, CRDEstablished()); err != nil {
+			return err
+		}
+
+		crd, err := k.GetCRD(name)
+		if err != nil {
+			return err
+		}
+		for _, v := range crd.Versions {
+			if !v.Served {
+				continue
+			}
+			if err := k.WaitForAPIResource(crd.Group+"/"+v.Name, crd.Plural); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WaitForAPIResource waits for the API discovery to expose the resource in
+// the group version, e.g. ("elemental.cattle.io/v1beta1",
+// "machineregistrations"), and refreshes the REST mapper
+func (k *Kubectl) WaitForAPIResource(groupVersion, resource string) error {
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	err = wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
+		list, err := c.Clientset.Discovery().ServerResourcesForGroupVersion(groupVersion)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, r := range list.APIResources {
+			if r.Name == resource {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "waiting for %s in %s to be discovered", resource, groupVersion)
+	}
+
+	c.resetMapper()
+	return nil
+}
+
+// resetMapper refreshes the discovery of the REST mapper
+func (c *ClientBackend) resetMapper() {
+	if r, ok := c.Mapper.(meta.ResettableRESTMapper); ok {
+		r.Reset()
+	}
+}
+
+// InstallCRDs applies the CRDs of the files or directories and waits for
+// them to be served, see WaitForCRDs. The manifest can be removed with
+// DeleteManifest.
+func (k *Kubectl) InstallCRDs(paths ...string) (*Manifest, error) {
+	m, err := LoadManifestFiles(nil, paths...)
+	if err != nil {
+		return nil, err
+	}
+	return m, k.installCRDs(m)
+}
+
+// InstallCRDs applies the CRDs of the chart, like helm show crds, and waits
+// for them to be served, see WaitForCRDs. Only the Version and Devel
+// options are used, the manifest can be removed with Kubectl.DeleteManifest.
+func (h *Helm) InstallCRDs(_ context.Context, chart string, opts HelmOptions) (*Manifest, error) {
+	cfg, err := h.configuration(h.namespace(opts.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	show := action.NewShowWithConfig(action.ShowCRDs, cfg)
+	show.Version = chartVersion(opts)
+	show.Devel = opts.Devel
+	ch, err := h.locateChart(&show.ChartPathOptions, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, crd := range ch.CRDObjects() {
+		out.WriteString("---\n")
+		out.Write(crd.File.Data)
+		out.WriteString("\n")
+	}
+
+	m, err := RenderManifest(chart, out.Bytes(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return m, h.k.installCRDs(m)
+}
+
+// installCRDs applies the CRDs of the manifest, ApplyManifest waits for them
+func (k *Kubectl) installCRDs(m *Manifest) error {
+	for _, obj := range m.Objects {
+		if kindOrder(obj.GroupVersionKind().GroupKind()) != 0 {
+			return errors.Errorf("%s is not a CustomResourceDefinition", refOf(obj))
+		}
+	}
+	if len(m.Objects) == 0 {
+		return errors.New("no CustomResourceDefinition found")
+	}
+
+	return k.ApplyManifest("", m)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// registrationCRD returns the CRD of the MachineRegistrations with the
+// given Established and NamesAccepted statuses, empty statuses are omitted
+func registrationCRD(established, accepted string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "machineregistrations.elemental.cattle.io"},
+		"spec": map[string]interface{}{
+			"group": "elemental.cattle.io",
+			"scope": "Namespaced",
+			"names": map[string]interface{}{"kind": "MachineRegistration", "plural": "machineregistrations"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "served": false, "storage": false},
+				map[string]interface{}{"name": "v1beta1", "served": true, "storage": true},
+			},
+		},
+	}}
+
+	var conditions []interface{}
+	if established != "" {
+		conditions = append(conditions, map[string]interface{}{"type": "Established", "status": established})
+	}
+	if accepted != "" {
+		conditions = append(conditions, map[string]interface{}{
+			"type": "NamesAccepted", "status": accepted, "reason": "MultipleNamesConflict", "message": "plural conflicts",
+		})
+	}
+	if conditions != nil {
+		Expect(unstructured.SetNestedSlice(crd.Object, conditions, "status", "conditions")).To(Succeed())
+	}
+	return crd
+}
+
+// discoverLater exposes the MachineRegistrations in the API discovery once
+// the returned function is called
+func discoverLater(k *Kubectl) func() {
+	cs := k.Backend.(*ClientBackend).Clientset.(*fake.Clientset)
+	cs.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "elemental.cattle.io/v1beta1",
+		APIResources: []metav1.APIResource{{Name: "machineregistrations", Kind: "MachineRegistration", Namespaced: true}},
+	}}
+
+	var discovered atomic.Bool
+	cs.PrependReactor("get", "resource", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if discovered.Load() {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{}, "elemental.cattle.io/v1beta1")
+	})
+	return func() { discovered.Store(true) }
+}
+
+var _ = Describe("CRDs", func() {
+	crdResource := crdKind.GroupVersion().WithResource("customresourcedefinitions")
+
+	It("describes the served and storage versions", func() {
+		k := newFakeKubectl(registrationCRD("True", "True"))
+
+		crd, err := k.GetCRD("machineregistrations.elemental.cattle.io")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(crd.Established).To(BeTrue())
+		Expect(crd.Plural).To(Equal("machineregistrations"))
+		Expect(crd.Serves("v1beta1")).To(BeTrue())
+		Expect(crd.Serves("v1alpha1")).To(BeFalse())
+		Expect(crd.StorageVersion()).To(Equal("v1beta1"))
+	})
+
+	It("waits for CRDs to be established and discovered", func() {
+		k := newFakeKubectl(registrationCRD("False", ""))
+		discover := discoverLater(k)
+
+		updateLater(k, crdResource, registrationCRD("True", "True"))
+		time.AfterFunc(300*time.Millisecond, discover)
+
+		start := time.Now()
+		Expect(k.WaitForCRDs("machineregistrations.elemental.cattle.io")).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	It("stops when the names are not accepted", func() {
+		k := newFakeKubectl(registrationCRD("False", "False"))
+
+		err := k.WaitForCRDs("machineregistrations.elemental.cattle.io")
+		Expect(err).To(MatchError(ContainSubstring("not accepted: MultipleNamesConflict plural conflicts")))
+	})
+
+	It("reports resources missing from the discovery", func() {
+		k := newFakeKubectl()
+		k.PollTimeout = 200 * time.Millisecond
+		discoverLater(k)
+
+		err := k.WaitForAPIResource("elemental.cattle.io/v1beta1", "machineregistrations")
+		Expect(err).To(MatchError(ContainSubstring("waiting for machineregistrations in elemental.cattle.io/v1beta1 to be discovered")))
+	})
+
+	It("installs CRDs from files", func() {
+		data, err := yaml.Marshal(registrationCRD("", "").Object)
+		Expect(err).ToNot(HaveOccurred())
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "crds.yaml"), data, 0o600)).To(Succeed())
+
+		k := newFakeKubectl()
+		discoverLater(k)()
+		updateLater(k, crdResource, registrationCRD("True", "True"))
+
+		m, err := k.InstallCRDs(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.Created).To(HaveLen(1))
+		Expect(k.DeleteManifest(m)).To(Succeed())
+	})
+
+	It("installs the CRDs of charts", func() {
+		data, err := yaml.Marshal(registrationCRD("", "").Object)
+		Expect(err).ToNot(HaveOccurred())
+
+		k := newFakeKubectl()
+		discoverLater(k)()
+		helm := newMemoryHelm(k)
+
+		updateLater(k, crdResource, registrationCRD("True", "True"))
+		_, err = helm.InstallCRDs(context.Background(), writeChart("1.7.0", string(data)), HelmOptions{Version: "1.7.0"})
+		Expect(err).ToNot(HaveOccurred())
+
+		chart := writeChart("1.7.0", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n")
+		_, err = helm.InstallCRDs(context.Background(), chart, HelmOptions{})
+		Expect(err).To(MatchError(ContainSubstring("ConfigMap test is not a CustomResourceDefinition")))
+	})
+})
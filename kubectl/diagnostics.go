@@ -0,0 +1,339 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// DiagnosticsOptions selects what DumpDiagnostics collects
+type DiagnosticsOptions struct {
+	// Dir is the directory the diagnostics are written to
+	Dir string
+	// Namespaces are the namespaces whose pods, logs, events, deployments,
+	// daemonsets and custom resources are dumped
+	Namespaces []string
+	// APIGroups are the groups of the custom resources to dump, e.g.
+	// elemental.cattle.io or provisioning.cattle.io
+	APIGroups []string
+}
+
+// DumpDiagnostics writes a snapshot of the cluster to opts.Dir:
+//
+//	nodes/<node>.yaml
+//	nodes/conditions.txt
+//	cluster/<resource>/<name>.yaml                 cluster scoped custom resources
+//	<namespace>/events.txt                         sorted by time
+//	<namespace>/pods/<pod>.yaml
+//	<namespace>/pods/<pod>/<container>.log
+//	<namespace>/pods/<pod>/<container>.previous.log  restarted containers only
+//	<namespace>/deployments/<name>.yaml
+//	<namespace>/daemonsets/<name>.yaml
+//	<namespace>/<resource>/<name>.yaml             custom resources
+//
+// The collection goes on when a part fails, all the errors are returned.
+func (k *Kubectl) DumpDiagnostics(opts DiagnosticsOptions) error {
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	d := &diagnostics{k: k, c: c, dir: opts.Dir}
+	d.nodes()
+
+	resources := d.customResources(opts.APIGroups)
+	for _, m := range resources {
+		if m.Scope.Name() != meta.RESTScopeNameNamespace {
+			d.objects("", resourceArg(m), filepath.Join("cluster", resourceArg(m)))
+		}
+	}
+
+	for _, ns := range opts.Namespaces {
+		d.pods(ns)
+		d.events(ns)
+		d.objects(ns, "deployments.v1.apps", filepath.Join(ns, "deployments"))
+		d.objects(ns, "daemonsets.v1.apps", filepath.Join(ns, "daemonsets"))
+		for _, m := range resources {
+			if m.Scope.Name() == meta.RESTScopeNameNamespace {
+				d.objects(ns, resourceArg(m), filepath.Join(ns, resourceArg(m)))
+			}
+		}
+	}
+
+	return utilerrors.NewAggregate(d.errs)
+}
+
+// DumpOnFailure dumps the diagnostics after the spec if it failed, in a
+// sub-directory of opts.Dir named after the spec. It must be called from a
+// Ginkgo node, e.g. BeforeEach, the path is added to the spec report.
+func (k *Kubectl) DumpOnFailure(opts DiagnosticsOptions) {
+	ginkgo.DeferCleanup(func() {
+		report := ginkgo.CurrentSpecReport()
+		if !report.Failed() {
+			return
+		}
+
+		specOpts := opts
+		specOpts.Dir = filepath.Join(opts.Dir, specDirName(report.FullText()))
+		if err := k.DumpDiagnostics(specOpts); err != nil {
+			ginkgo.GinkgoWriter.Printf("Diagnostics are incomplete: %s\n", err)
+		}
+		ginkgo.AddReportEntry("Diagnostics", specOpts.Dir)
+	})
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// specDirName returns a directory name for the spec text
+func specDirName(text string) string {
+	name := strings.Trim(unsafeChars.ReplaceAllString(text, "-"), "-")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return fmt.Sprintf("%s-%d", name, time.Now().Unix())
+}
+
+// resourceArg returns the fully qualified resource name of the mapping
+func resourceArg(m *meta.RESTMapping) string {
+	if m.Resource.Group == "" {
+		return m.Resource.Resource
+	}
+	return m.Resource.Resource + "." + m.Resource.Version + "." + m.Resource.Group
+}
+
+// diagnostics collects the files and the errors of a dump
+type diagnostics struct {
+	k    *Kubectl
+	c    *ClientBackend
+	dir  string
+	errs []error
+}
+
+// write writes the file, the directories are created
+func (d *diagnostics) write(name string, data []byte) {
+	path := filepath.Join(d.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		d.errs = append(d.errs, errors.Wrapf(err, "writing %s", name))
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		d.errs = append(d.errs, errors.Wrapf(err, "writing %s", name))
+	}
+}
+
+// writeObject writes the object as YAML, without the managed fields
+func (d *diagnostics) writeObject(name string, obj *unstructured.Unstructured) {
+	obj = obj.DeepCopy()
+	obj.SetManagedFields(nil)
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		d.errs = append(d.errs, errors.Wrapf(err, "encoding %s", name))
+		return
+	}
+	d.write(name, data)
+}
+
+// list lists the resources, failures are recorded
+func (d *diagnostics) list(namespace, resource string) []unstructured.Unstructured {
+	objs, err := ListResource[unstructured.Unstructured](d.k, namespace, resource, ListOptions{})
+	if err != nil {
+		d.errs = append(d.errs, errors.Wrapf(err, "listing %s in namespace '%s'", resource, namespace))
+	}
+	return objs
+}
+
+// objects dumps the resources of the namespace in dir
+func (d *diagnostics) objects(namespace, resource, dir string) {
+	for _, obj := range d.list(namespace, resource) {
+		d.writeObject(filepath.Join(dir, obj.GetName()+".yaml"), &obj)
+	}
+}
+
+// nodes dumps the nodes and a summary of their conditions
+func (d *diagnostics) nodes() {
+	var summary strings.Builder
+	for _, obj := range d.list("", "nodes") {
+		d.writeObject(filepath.Join("nodes", obj.GetName()+".yaml"), &obj)
+
+		var node corev1.Node
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &node); err != nil {
+			d.errs = append(d.errs, err)
+			continue
+		}
+		for _, c := range node.Status.Conditions {
+			fmt.Fprintf(&summary, "%s\t%s=%s\t%s\t%s\n", node.Name, c.Type, c.Status, c.Reason, c.Message)
+		}
+	}
+	d.write(filepath.Join("nodes", "conditions.txt"), []byte(summary.String()))
+}
+
+// pods dumps the pods of the namespace with the logs of their containers
+func (d *diagnostics) pods(namespace string) {
+	for _, obj := range d.list(namespace, "pods") {
+		d.writeObject(filepath.Join(namespace, "pods", obj.GetName()+".yaml"), &obj)
+
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod); err != nil {
+			d.errs = append(d.errs, err)
+			continue
+		}
+
+		restarts := map[string]int32{}
+		for _, s := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			restarts[s.Name] = s.RestartCount
+		}
+
+		for _, container := range logContainers(&pod, LogOptions{}) {
+			dir := filepath.Join(namespace, "pods", pod.Name)
+			d.logs(namespace, pod.Name, filepath.Join(dir, container+".log"), LogOptions{Container: container, Timestamps: true})
+			if restarts[container] > 0 {
+				d.logs(namespace, pod.Name, filepath.Join(dir, container+".previous.log"), LogOptions{Container: container, Timestamps: true, Previous: true})
+			}
+		}
+	}
+}
+
+// logs dumps the logs of a container
+func (d *diagnostics) logs(namespace, pod, name string, opts LogOptions) {
+	out, err := d.k.GetPodLogs(namespace, pod, opts)
+	if err != nil {
+		d.errs = append(d.errs, err)
+		return
+	}
+	d.write(name, []byte(out))
+}
+
+// events dumps the events of the namespace, oldest first
+func (d *diagnostics) events(namespace string) {
+	var events []corev1.Event
+	for _, obj := range d.list(namespace, "events") {
+		var event corev1.Event
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &event); err != nil {
+			d.errs = append(d.errs, err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(&events[i]).Before(eventTime(&events[j]))
+	})
+
+	var out strings.Builder
+	for _, e := range events {
+		fmt.Fprintf(&out, "%s\t%s\t%s\t%s/%s\t%s\n", eventTime(&e).Format(time.RFC3339), e.Type, e.Reason,
+			e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Message)
+	}
+	d.write(filepath.Join(namespace, "events.txt"), []byte(out.String()))
+}
+
+// eventTime returns the last time the event was seen
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// customResources returns the mappings of the resources of the API groups,
+// each kind is mapped once with the preferred version of its group, or
+// with another served version if the preferred one does not have it
+func (d *diagnostics) customResources(groups []string) []*meta.RESTMapping {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	apiGroups, lists, err := d.c.Clientset.Discovery().ServerGroupsAndResources()
+	if err != nil && len(lists) == 0 {
+		d.errs = append(d.errs, errors.Wrap(err, "discovering the API resources"))
+		return nil
+	}
+
+	preferred := map[string]string{}
+	for _, g := range apiGroups {
+		preferred[g.Name] = g.PreferredVersion.Version
+	}
+
+	// The preferred versions go first, the other served versions are only
+	// used for the kinds missing from them
+	isPreferred := func(list *metav1.APIResourceList) bool {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		return err == nil && preferred[gv.Group] == gv.Version
+	}
+	lists = slices.Clone(lists)
+	slices.SortStableFunc(lists, func(a, b *metav1.APIResourceList) int {
+		switch {
+		case isPreferred(a) && !isPreferred(b):
+			return -1
+		case !isPreferred(a) && isPreferred(b):
+			return 1
+		}
+		return 0
+	})
+
+	var mappings []*meta.RESTMapping
+	seen := map[schema.GroupKind]bool{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || !slices.Contains(groups, gv.Group) {
+			continue
+		}
+
+		for _, r := range list.APIResources {
+			// Skip the subresources, e.g. clusters/status
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+
+			gk := gv.WithKind(r.Kind).GroupKind()
+			if seen[gk] {
+				continue
+			}
+			seen[gk] = true
+
+			m, err := d.c.restMapping(gk, gv.Version)
+			if err != nil {
+				d.errs = append(d.errs, errors.Wrapf(err, "mapping %s", r.Name))
+				continue
+			}
+			mappings = append(mappings, m)
+		}
+	}
+	return mappings
+}
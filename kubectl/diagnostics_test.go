@@ -0,0 +1,157 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+func event(name, reason string, at time.Time) *corev1.Event {
+	return &corev1.Event{
+		TypeMeta:       metav1.TypeMeta{APIVersion: "v1", Kind: "Event"},
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "operator-1"},
+		Reason:         reason,
+		Type:           corev1.EventTypeWarning,
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+var _ = Describe("diagnostics", func() {
+	It("dumps the state of the namespaces", func() {
+		now := time.Now()
+
+		pod := operatorPod("operator-1")
+		pod.Status.ContainerStatuses[0].Name = "operator"
+		pod.Status.ContainerStatuses[0].RestartCount = 2
+
+		node := &corev1.Node{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Node"},
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{{
+				Type:   corev1.NodeReady,
+				Status: corev1.ConditionTrue,
+				Reason: "KubeletReady",
+			}}},
+		}
+
+		registration := &unstructured.Unstructured{}
+		registration.SetGroupVersionKind(machineRegistrationKind)
+		registration.SetNamespace("default")
+		registration.SetName("fire-nodes")
+
+		k := newFakeKubectl(pod, node, registration,
+			event("second", "BackOff", now),
+			event("first", "Pulled", now.Add(-time.Minute)))
+		k.Backend.(*ClientBackend).Clientset.(*fake.Clientset).Resources = []*metav1.APIResourceList{{
+			GroupVersion: "elemental.cattle.io/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "machineregistrations", Kind: "MachineRegistration", Namespaced: true},
+				{Name: "machineregistrations/status", Kind: "MachineRegistration", Namespaced: true},
+			},
+		}, {
+			// Served too but not preferred, the fake mapper does not know it
+			GroupVersion: "elemental.cattle.io/v1alpha1",
+			APIResources: []metav1.APIResource{{Name: "machineregistrations", Kind: "MachineRegistration", Namespaced: true}},
+		}}
+
+		dir := GinkgoT().TempDir()
+		Expect(k.DumpDiagnostics(DiagnosticsOptions{
+			Dir:        dir,
+			Namespaces: []string{"default"},
+			APIGroups:  []string{"elemental.cattle.io"},
+		})).To(Succeed())
+
+		Expect(filepath.Join(dir, "nodes", "node-1.yaml")).To(BeAnExistingFile())
+		conditions, err := os.ReadFile(filepath.Join(dir, "nodes", "conditions.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(conditions)).To(ContainSubstring("node-1\tReady=True\tKubeletReady"))
+
+		podYAML, err := os.ReadFile(filepath.Join(dir, "default", "pods", "operator-1.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(podYAML)).To(ContainSubstring("name: operator-1"))
+		Expect(string(podYAML)).ToNot(ContainSubstring("managedFields"))
+
+		Expect(filepath.Join(dir, "default", "pods", "operator-1", "init.log")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "default", "pods", "operator-1", "operator.log")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "default", "pods", "operator-1", "operator.previous.log")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "default", "pods", "operator-1", "init.previous.log")).ToNot(BeAnExistingFile())
+
+		events, err := os.ReadFile(filepath.Join(dir, "default", "events.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(string(events)), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(ContainSubstring("Pulled"))
+		Expect(lines[1]).To(ContainSubstring("BackOff\tPod/operator-1"))
+
+		Expect(filepath.Join(dir, "default", "machineregistrations.v1beta1.elemental.cattle.io", "fire-nodes.yaml")).To(BeAnExistingFile())
+	})
+
+	It("dumps the kinds missing from the preferred version with another version", func() {
+		registration := &unstructured.Unstructured{}
+		registration.SetGroupVersionKind(machineRegistrationKind)
+		registration.SetNamespace("default")
+		registration.SetName("fire-nodes")
+		selector := &unstructured.Unstructured{}
+		selector.SetGroupVersionKind(machineInventorySelectorKind)
+		selector.SetNamespace("default")
+		selector.SetName("fire-selector")
+
+		k := newFakeKubectl(registration, selector)
+		k.Backend.(*ClientBackend).Clientset.(*fake.Clientset).Resources = []*metav1.APIResourceList{{
+			GroupVersion: "elemental.cattle.io/v1beta1",
+			APIResources: []metav1.APIResource{{Name: "machineregistrations", Kind: "MachineRegistration", Namespaced: true}},
+		}, {
+			GroupVersion: "elemental.cattle.io/v1alpha1",
+			APIResources: []metav1.APIResource{
+				{Name: "machineregistrations", Kind: "MachineRegistration", Namespaced: true},
+				{Name: "machineinventoryselectors", Kind: "MachineInventorySelector", Namespaced: true},
+			},
+		}}
+
+		dir := GinkgoT().TempDir()
+		Expect(k.DumpDiagnostics(DiagnosticsOptions{
+			Dir:        dir,
+			Namespaces: []string{"default"},
+			APIGroups:  []string{"elemental.cattle.io"},
+		})).To(Succeed())
+
+		Expect(filepath.Join(dir, "default", "machineregistrations.v1beta1.elemental.cattle.io", "fire-nodes.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "default", "machineregistrations.v1alpha1.elemental.cattle.io")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(dir, "default", "machineinventoryselectors.v1alpha1.elemental.cattle.io", "fire-selector.yaml")).To(BeAnExistingFile())
+	})
+
+	It("reports the parts which could not be collected", func() {
+		k := newFakeKubectl()
+
+		err := k.DumpDiagnostics(DiagnosticsOptions{Dir: GinkgoT().TempDir(), Namespaces: []string{"default"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = k.DumpDiagnostics(DiagnosticsOptions{Dir: "/dev/null/diagnostics"})
+		Expect(err).To(MatchError(ContainSubstring("writing nodes/conditions.txt")))
+	})
+})
@@ -0,0 +1,217 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onsi/gomega/types"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// EventFilter selects events, empty fields match all the events
+type EventFilter struct {
+	// Kind and Name select the involved object, e.g. "MachineInventory"
+	Kind string
+	Name string
+	// Reason is the reason of the event, e.g. "Registered"
+	Reason string
+	// Type is the type of the event, "Normal" or "Warning"
+	Type string
+	// Message is a regular expression matching the message
+	Message string
+	// Since ignores the events last seen before the time
+	Since time.Time
+}
+
+// matcher returns a function matching the filter
+func (f EventFilter) matcher() (func(e *corev1.Event) bool, error) {
+	message, err := regexp.Compile(f.Message)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid message expression %s", f.Message)
+	}
+
+	return func(e *corev1.Event) bool {
+		return (f.Kind == "" || e.InvolvedObject.Kind == f.Kind) &&
+			(f.Name == "" || e.InvolvedObject.Name == f.Name) &&
+			(f.Reason == "" || e.Reason == f.Reason) &&
+			(f.Type == "" || e.Type == f.Type) &&
+			message.MatchString(e.Message) &&
+			!eventTime(e).Before(f.Since)
+	}, nil
+}
+
+// ListEvents returns the events of the namespace matching the filter,
+// oldest first. An empty namespace lists the events of all the namespaces.
+func (k *Kubectl) ListEvents(namespace string, filter EventFilter) ([]corev1.Event, error) {
+	match, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := List[corev1.Event](k, namespace, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []corev1.Event
+	for i := range events {
+		if match(&events[i]) {
+			matching = append(matching, events[i])
+		}
+	}
+	sortEvents(matching)
+	return matching, nil
+}
+
+// Events returns a function listing the events, to poll them with
+// Eventually, e.g.
+//
+//	Eventually(k.Events("fleet-default", EventFilter{Kind: "MachineInventory"})).
+//		Should(HaveEmittedEvent("Adopted", "adopted by .*"))
+func (k *Kubectl) Events(namespace string, filter EventFilter) func() ([]corev1.Event, error) {
+	return func() ([]corev1.Event, error) {
+		return k.ListEvents(namespace, filter)
+	}
+}
+
+// WaitForEvent watches the events of the namespace until one matches the
+// filter and returns it, the timeout error reports the last events
+func (k *Kubectl) WaitForEvent(namespace string, filter EventFilter) (*corev1.Event, error) {
+	match, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *corev1.Event
+	err = k.watchFor(namespace, "events", WatchOptions{}, func(objs []*unstructured.Unstructured) (bool, error) {
+		events, err := decodeEvents(objs)
+		if err != nil {
+			return false, err
+		}
+		for i := range events {
+			if match(&events[i]) {
+				found = &events[i]
+				return true, nil
+			}
+		}
+		return false, nil
+	}, func(objs []*unstructured.Unstructured) string {
+		events, err := decodeEvents(objs)
+		if err != nil {
+			return err.Error()
+		}
+		return describeEvents(events, 10)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for event %+v", filter)
+	}
+	return found, nil
+}
+
+// decodeEvents converts the watched events, oldest first
+func decodeEvents(objs []*unstructured.Unstructured) ([]corev1.Event, error) {
+	events := make([]corev1.Event, len(objs))
+	for i, obj := range objs {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &events[i]); err != nil {
+			return nil, err
+		}
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// sortEvents sorts the events by time, oldest first
+func sortEvents(events []corev1.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(&events[i]).Before(eventTime(&events[j]))
+	})
+}
+
+// describeEvents summarizes the last events
+func describeEvents(events []corev1.Event, last int) string {
+	if len(events) == 0 {
+		return "no event found"
+	}
+	if len(events) > last {
+		events = events[len(events)-last:]
+	}
+
+	var desc []string
+	for _, e := range events {
+		desc = append(desc, fmt.Sprintf("%s %s %s/%s: %s", e.Type, e.Reason, e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Message))
+	}
+	return strings.Join(desc, "\n")
+}
+
+// emittedEventMatcher matches a list of events containing an event
+type emittedEventMatcher struct {
+	reason  string
+	message string
+}
+
+// HaveEmittedEvent succeeds if the []corev1.Event contains an event with the
+// reason and a message matching the regular expression, see Events
+func HaveEmittedEvent(reason, messageRegex string) types.GomegaMatcher {
+	return &emittedEventMatcher{reason: reason, message: messageRegex}
+}
+
+func (m *emittedEventMatcher) Match(actual interface{}) (bool, error) {
+	events, err := toEvents(actual)
+	if err != nil {
+		return false, err
+	}
+
+	match, err := EventFilter{Reason: m.reason, Message: m.message}.matcher()
+	if err != nil {
+		return false, err
+	}
+	for i := range events {
+		if match(&events[i]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *emittedEventMatcher) FailureMessage(actual interface{}) string {
+	events, _ := toEvents(actual)
+	return fmt.Sprintf("Expected an event %s with a message matching %q, got:\n%s", m.reason, m.message, describeEvents(events, 20))
+}
+
+func (m *emittedEventMatcher) NegatedFailureMessage(actual interface{}) string {
+	events, _ := toEvents(actual)
+	return fmt.Sprintf("Expected no event %s with a message matching %q, got:\n%s", m.reason, m.message, describeEvents(events, 20))
+}
+
+// toEvents returns the events of the actual value
+func toEvents(actual interface{}) ([]corev1.Event, error) {
+	switch events := actual.(type) {
+	case []corev1.Event:
+		return events, nil
+	case *corev1.EventList:
+		return events.Items, nil
+	case corev1.EventList:
+		return events.Items, nil
+	}
+	return nil, errors.Errorf("HaveEmittedEvent expects []corev1.Event, got %T", actual)
+}
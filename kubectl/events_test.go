@@ -0,0 +1,98 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// inventoryEvent returns an event of a machine inventory
+func inventoryEvent(name, reason, message string, at time.Time) *corev1.Event {
+	e := event(name, reason, at)
+	e.Type = corev1.EventTypeNormal
+	e.InvolvedObject = corev1.ObjectReference{Kind: "MachineInventory", Name: "m-1234"}
+	e.Message = message
+	return e
+}
+
+var _ = Describe("events", func() {
+	var (
+		k   *Kubectl
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		k = newFakeKubectl(
+			inventoryEvent("adopted", "Adopted", "adopted by cluster test", now),
+			inventoryEvent("registered", "Registered", "registered with TPM", now.Add(-time.Hour)),
+			event("backoff", "BackOff", now.Add(-time.Minute)),
+		)
+		k.PollTimeout = 300 * time.Millisecond
+	})
+
+	It("lists the matching events oldest first", func() {
+		events, err := k.ListEvents("default", EventFilter{Kind: "MachineInventory", Name: "m-1234"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Reason).To(Equal("Registered"))
+		Expect(events[1].Reason).To(Equal("Adopted"))
+
+		events, err = k.ListEvents("default", EventFilter{Type: corev1.EventTypeWarning})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(1))
+
+		events, err = k.ListEvents("default", EventFilter{Message: "^registered", Since: now.Add(-time.Minute)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(BeEmpty())
+
+		_, err = k.ListEvents("default", EventFilter{Message: "("})
+		Expect(err).To(MatchError(ContainSubstring("invalid message expression")))
+	})
+
+	It("matches emitted events", func() {
+		Eventually(k.Events("default", EventFilter{Kind: "MachineInventory"})).Should(HaveEmittedEvent("Adopted", "by cluster \\w+"))
+		Expect(k.ListEvents("default", EventFilter{})).ToNot(HaveEmittedEvent("Adopted", "by cluster other"))
+
+		matcher := HaveEmittedEvent("Deleted", "")
+		events, err := k.ListEvents("default", EventFilter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(matcher.Match(events)).To(BeFalse())
+		Expect(matcher.FailureMessage(events)).To(ContainSubstring("Normal Adopted MachineInventory/m-1234: adopted by cluster test"))
+
+		_, err = matcher.Match("events")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("waits for events", func() {
+		updateLater(k, corev1.SchemeGroupVersion.WithResource("events"), inventoryEvent("deleted", "Deleted", "machine deleted", now))
+
+		k.PollTimeout = 2 * time.Second
+		e, err := k.WaitForEvent("default", EventFilter{Reason: "Deleted", Since: now.Truncate(time.Second)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Message).To(Equal("machine deleted"))
+	})
+
+	It("reports the last events on timeout", func() {
+		_, err := k.WaitForEvent("default", EventFilter{Reason: "Deleted"})
+		Expect(err).To(MatchError(And(ContainSubstring("timed out"), ContainSubstring("Warning BackOff Pod/operator-1"))))
+	})
+})
@@ -0,0 +1,153 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"path"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecOptions configures Exec
+type ExecOptions struct {
+	// Container is the container to run the command in, it can be omitted
+	// if the pod has a single container
+	Container string
+	// Stdin is passed to the command if not nil
+	Stdin io.Reader
+	// TTY allocates a terminal, stderr is then merged into stdout
+	TTY bool
+}
+
+// ExecResult is the outcome of a command run by Exec
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExecutorFunc creates the executor streaming a command, url is the exec
+// subresource of the pod
+type ExecutorFunc func(method string, u *url.URL) (remotecommand.Executor, error)
+
+// Exec runs the command in the pod without a shell, command is the argv of
+// the process. A command exiting with a non-zero code is not an error, the
+// code is returned in the result. The command is stopped when the context
+// is done.
+func (k *Kubectl) Exec(ctx context.Context, namespace, pod string, command []string, opts ExecOptions) (*ExecResult, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+	if len(command) == 0 {
+		return nil, errors.New("no command to run")
+	}
+
+	u, err := c.podURL(namespace, pod, "exec")
+	if err != nil {
+		return nil, err
+	}
+	query, err := scheme.ParameterCodec.EncodeParameters(&corev1.PodExecOptions{
+		Container: opts.Container,
+		Command:   command,
+		Stdin:     opts.Stdin != nil,
+		Stdout:    true,
+		Stderr:    !opts.TTY,
+		TTY:       opts.TTY,
+	}, corev1.SchemeGroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+
+	executor, err := c.executor("POST", u)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: &stdout,
+		Tty:    opts.TTY,
+	}
+	if !opts.TTY {
+		streamOptions.Stderr = &stderr
+	}
+
+	result := &ExecResult{}
+	err = executor.StreamWithContext(ctx, streamOptions)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		result.ExitCode = exitErr.ExitStatus()
+		return result, nil
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, "running %v in pod %s", command, pod)
+	}
+	return result, nil
+}
+
+// podURL returns the URL of a subresource of the pod
+func (c *ClientBackend) podURL(namespace, pod, subresource string) (*url.URL, error) {
+	u := &url.URL{}
+	if c.Config != nil {
+		var err error
+		if u, _, err = rest.DefaultServerUrlFor(c.Config); err != nil {
+			return nil, err
+		}
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	u.Path = path.Join("/", u.Path, "api", "v1", "namespaces", namespace, "pods", pod, subresource)
+	return u, nil
+}
+
+// executor returns the executor of the URL, commands are streamed over
+// websockets and fall back to SPDY on older API servers like kubectl does
+func (c *ClientBackend) executor(method string, u *url.URL) (remotecommand.Executor, error) {
+	if c.NewExecutor != nil {
+		return c.NewExecutor(method, u)
+	}
+	if c.Config == nil {
+		return nil, errors.New("streaming commands needs a REST config")
+	}
+
+	spdy, err := remotecommand.NewSPDYExecutor(c.Config, method, u)
+	if err != nil {
+		return nil, err
+	}
+	websocket, err := remotecommand.NewWebSocketExecutor(c.Config, "GET", u.String())
+	if err != nil {
+		return nil, err
+	}
+	return remotecommand.NewFallbackExecutor(websocket, spdy, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+}
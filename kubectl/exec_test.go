@@ -0,0 +1,114 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// fakeExecutor echoes stdin to stdout, writes the command to stderr and
+// exits with code
+type fakeExecutor struct {
+	url  *url.URL
+	code int
+}
+
+func (e *fakeExecutor) Stream(opts remotecommand.StreamOptions) error {
+	return e.StreamWithContext(context.Background(), opts)
+}
+
+func (e *fakeExecutor) StreamWithContext(ctx context.Context, opts remotecommand.StreamOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if opts.Stdin != nil {
+		if _, err := io.Copy(opts.Stdout, opts.Stdin); err != nil {
+			return err
+		}
+	}
+	if opts.Stderr != nil {
+		fmt.Fprint(opts.Stderr, strings.Join(e.url.Query()["command"], " "))
+	}
+	if e.code != 0 {
+		return utilexec.CodeExitError{Err: errors.New("command terminated"), Code: e.code}
+	}
+	return nil
+}
+
+var _ = Describe("exec", func() {
+	var (
+		k        *Kubectl
+		executor *fakeExecutor
+	)
+
+	BeforeEach(func() {
+		k = newFakeKubectl()
+		executor = &fakeExecutor{}
+		k.Backend.(*ClientBackend).NewExecutor = func(method string, u *url.URL) (remotecommand.Executor, error) {
+			executor.url = u
+			return executor, nil
+		}
+	})
+
+	It("runs the command with separate streams", func() {
+		result, err := k.Exec(context.Background(), "cattle-system", "operator-1", []string{"cat", "/etc/os-release"},
+			ExecOptions{Container: "operator", Stdin: strings.NewReader("input")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(&ExecResult{Stdout: "input", Stderr: "cat /etc/os-release"}))
+
+		Expect(executor.url.Path).To(Equal("/api/v1/namespaces/cattle-system/pods/operator-1/exec"))
+		query := executor.url.Query()
+		Expect(query["command"]).To(Equal([]string{"cat", "/etc/os-release"}))
+		Expect(query.Get("container")).To(Equal("operator"))
+		Expect(query.Get("stdin")).To(Equal("true"))
+		Expect(query.Get("stderr")).To(Equal("true"))
+		Expect(query.Get("tty")).To(BeEmpty())
+	})
+
+	It("returns the exit code of the command", func() {
+		executor.code = 3
+
+		result, err := k.Exec(context.Background(), "default", "operator-1", []string{"false"}, ExecOptions{TTY: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ExitCode).To(Equal(3))
+		Expect(result.Stderr).To(BeEmpty())
+		Expect(executor.url.Query().Get("tty")).To(Equal("true"))
+	})
+
+	It("stops with the context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := k.Exec(ctx, "default", "operator-1", []string{"sleep", "60"}, ExecOptions{})
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("needs a command", func() {
+		_, err := k.Exec(context.Background(), "default", "operator-1", nil, ExecOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+})
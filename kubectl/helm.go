@@ -0,0 +1,505 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/registry"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Helm manages Helm releases with the kubeconfig and context of a Kubectl.
+// It uses the Helm SDK, so the releases are stored like helm does and can
+// be managed from the command line.
+type Helm struct {
+	// Settings are the Helm settings, e.g. the repositories file and cache.
+	// The kubeconfig and context are the ones of the Kubectl.
+	Settings *cli.EnvSettings
+	// Driver is the storage driver of the releases, "secret" if empty
+	Driver string
+	// Configuration returns the action configuration of the namespace, it
+	// is built from the kubeconfig and context of the Kubectl if nil
+	Configuration func(namespace string) (*action.Configuration, error)
+
+	k *Kubectl
+}
+
+// Helm returns a Helm client using the kubeconfig and context of k
+func (k *Kubectl) Helm() *Helm {
+	settings := cli.New()
+	settings.KubeConfig = k.Kubeconfig
+	settings.KubeContext = k.Context
+	return &Helm{Settings: settings, Driver: os.Getenv("HELM_DRIVER"), k: k}
+}
+
+// Values are the values of a chart, nested maps follow the structure of
+// the values.yaml of the chart
+type Values map[string]interface{}
+
+// Set sets the value of a dotted path, e.g. Set("ingress.tls.source",
+// "secret"), creating the intermediate maps
+func (v Values) Set(path string, value interface{}) Values {
+	keys := strings.Split(path, ".")
+	m := map[string]interface{}(v)
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			if values, ok := m[key].(Values); ok {
+				next = map[string]interface{}(values)
+			} else {
+				next = map[string]interface{}{}
+				m[key] = next
+			}
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+	return v
+}
+
+// HelmOptions configures installs and upgrades
+type HelmOptions struct {
+	// Namespace is the namespace of the release
+	Namespace string
+	// CreateNamespace creates the namespace of the release if missing
+	CreateNamespace bool
+	// Version is the chart version, the latest stable version if empty
+	Version string
+	// Devel accepts development versions, e.g. release candidates
+	Devel bool
+	// Values are merged over the values of the ValuesFiles
+	Values Values
+	// ValuesFiles are values files, merged in order
+	ValuesFiles []string
+	// Wait waits for the resources of the release to be ready
+	Wait bool
+	// Timeout is the timeout of the helm operations, PollTimeout if zero
+	Timeout time.Duration
+	// Install installs the release on upgrade if it does not exist
+	Install bool
+	// ReuseValues reuses the values of the last release on upgrade
+	ReuseValues bool
+}
+
+// Release describes a Helm release
+type Release struct {
+	Name         string
+	Namespace    string
+	Revision     int
+	Status       string
+	Chart        string
+	ChartVersion string
+	AppVersion   string
+	Description  string
+	Notes        string
+	Updated      time.Time
+	// Values are the values supplied to the release
+	Values Values
+	// ComputedValues are the values supplied to the release merged with
+	// the default values of the chart, they are only set by Helm.Get
+	ComputedValues Values
+	// Manifest is the rendered manifest of the release
+	Manifest string
+}
+
+// ReleaseSummary is a release listed by Helm.List
+type ReleaseSummary struct {
+	Name      string
+	Namespace string
+	Revision  int
+	Status    string
+	// Chart is the name and the version of the chart, e.g. rancher-2.9.0
+	Chart      string
+	AppVersion string
+	Updated    time.Time
+}
+
+// configuration returns the action configuration of the namespace, with a
+// registry client for the OCI charts
+func (h *Helm) configuration(namespace string) (*action.Configuration, error) {
+	var cfg *action.Configuration
+	if h.Configuration != nil {
+		c, err := h.Configuration(namespace)
+		if err != nil {
+			return nil, err
+		}
+		cfg = c
+	} else {
+		flags := kube.GetConfig(h.k.Kubeconfig, h.k.Context, namespace)
+		cfg = &action.Configuration{}
+		if err := cfg.Init(flags, namespace, h.Driver, func(string, ...interface{}) {}); err != nil {
+			return nil, errors.Wrap(err, "initializing helm")
+		}
+	}
+
+	if cfg.RegistryClient == nil {
+		rc, err := registry.NewClient(
+			registry.ClientOptCredentialsFile(h.Settings.RegistryConfig),
+			registry.ClientOptWriter(io.Discard),
+			registry.ClientOptEnableCache(true),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating the registry client")
+		}
+		cfg.RegistryClient = rc
+	}
+	return cfg, nil
+}
+
+// namespace returns the namespace of the release, the namespace of the
+// Kubectl is used if namespace is empty, then the one of the context
+func (h *Helm) namespace(namespace string) string {
+	if namespace == "" {
+		namespace = h.k.Namespace
+	}
+	if namespace == "" {
+		namespace = h.Settings.Namespace()
+	}
+	return namespace
+}
+
+// timeout returns the timeout of the helm operations
+func (h *Helm) timeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return h.k.PollTimeout
+	}
+	return timeout
+}
+
+// RepoAdd adds or updates the chart repository and downloads its index
+func (h *Helm) RepoAdd(_ context.Context, name, url string) error {
+	entry := &repo.Entry{Name: name, URL: url}
+	if err := h.downloadIndex(entry); err != nil {
+		return err
+	}
+
+	f, err := repo.LoadFile(h.Settings.RepositoryConfig)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+	f.Update(entry)
+
+	if err := os.MkdirAll(filepath.Dir(h.Settings.RepositoryConfig), 0o755); err != nil {
+		return err
+	}
+	return errors.Wrapf(f.WriteFile(h.Settings.RepositoryConfig, 0o600), "adding repository %s", name)
+}
+
+// RepoUpdate updates the chart repositories, all of them if none is given
+func (h *Helm) RepoUpdate(_ context.Context, names ...string) error {
+	f, err := repo.LoadFile(h.Settings.RepositoryConfig)
+	if err != nil {
+		return err
+	}
+
+	entries := f.Repositories
+	if len(names) > 0 {
+		entries = nil
+		for _, name := range names {
+			entry := f.Get(name)
+			if entry == nil {
+				return errors.Errorf("no repository named %s", name)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if err := h.downloadIndex(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// downloadIndex downloads the index of the repository to the cache
+func (h *Helm) downloadIndex(entry *repo.Entry) error {
+	r, err := repo.NewChartRepository(entry, getter.All(h.Settings))
+	if err != nil {
+		return errors.Wrapf(err, "repository %s", entry.Name)
+	}
+	r.CachePath = h.Settings.RepositoryCache
+
+	_, err = r.DownloadIndexFile()
+	return errors.Wrapf(err, "downloading the index of %s", entry.Name)
+}
+
+// Install installs the chart as the release. The chart is a repository
+// chart (e.g. rancher-stable/rancher), a local path or an OCI reference
+// (e.g. oci://registry.example.com/charts/elemental-operator).
+func (h *Helm) Install(ctx context.Context, release, chart string, opts HelmOptions) (*Release, error) {
+	cfg, err := h.configuration(h.namespace(opts.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	return h.install(ctx, cfg, release, chart, opts)
+}
+
+// install installs the chart with the configuration
+func (h *Helm) install(ctx context.Context, cfg *action.Configuration, release, chart string, opts HelmOptions) (*Release, error) {
+	install := action.NewInstall(cfg)
+	install.ReleaseName = release
+	install.Namespace = h.namespace(opts.Namespace)
+	install.CreateNamespace = opts.CreateNamespace
+	install.Version = chartVersion(opts)
+	install.Devel = opts.Devel
+	install.Wait = opts.Wait
+	install.Timeout = h.timeout(opts.Timeout)
+
+	ch, vals, err := h.loadChart(&install.ChartPathOptions, chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := install.RunWithContext(ctx, ch, vals)
+	if err != nil {
+		return nil, errors.Wrapf(err, "helm install of %s", release)
+	}
+	return releaseOf(r), nil
+}
+
+// Upgrade upgrades the release to the chart, see Install for the charts
+func (h *Helm) Upgrade(ctx context.Context, release, chart string, opts HelmOptions) (*Release, error) {
+	cfg, err := h.configuration(h.namespace(opts.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Install {
+		history := action.NewHistory(cfg)
+		history.Max = 1
+		if _, err := history.Run(release); errors.Is(err, driver.ErrReleaseNotFound) {
+			return h.install(ctx, cfg, release, chart, opts)
+		}
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Namespace = h.namespace(opts.Namespace)
+	upgrade.Version = chartVersion(opts)
+	upgrade.Devel = opts.Devel
+	upgrade.ReuseValues = opts.ReuseValues
+	upgrade.Wait = opts.Wait
+	upgrade.Timeout = h.timeout(opts.Timeout)
+
+	ch, vals, err := h.loadChart(&upgrade.ChartPathOptions, chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := upgrade.RunWithContext(ctx, release, ch, vals)
+	if err != nil {
+		return nil, errors.Wrapf(err, "helm upgrade of %s", release)
+	}
+	return releaseOf(r), nil
+}
+
+// chartVersion returns the version constraint of the chart, development
+// versions are only accepted with Devel, like helm --devel
+func chartVersion(opts HelmOptions) string {
+	if opts.Devel && opts.Version == "" {
+		return ">0.0.0-0"
+	}
+	return opts.Version
+}
+
+// loadChart locates and loads the chart, and merges the values files with
+// the values of the options
+func (h *Helm) loadChart(paths *action.ChartPathOptions, name string, opts HelmOptions) (*chart.Chart, map[string]interface{}, error) {
+	ch, err := h.locateChart(paths, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := values.Options{ValueFiles: opts.ValuesFiles}
+	vals, err := files.MergeValues(getter.All(h.Settings))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The values are decoded like a values file, so nested Values are
+	// plain maps for the templates
+	data, err := json.Marshal(opts.Values)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "encoding values")
+	}
+	override := map[string]interface{}{}
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, nil, errors.Wrap(err, "decoding values")
+	}
+	return ch, mergeValues(vals, override), nil
+}
+
+// locateChart downloads the chart if needed and loads it
+func (h *Helm) locateChart(paths *action.ChartPathOptions, name string) (*chart.Chart, error) {
+	path, err := paths.LocateChart(name, h.Settings)
+	if err != nil {
+		return nil, errors.Wrapf(err, "locating chart %s", name)
+	}
+	ch, err := loader.Load(path)
+	return ch, errors.Wrapf(err, "loading chart %s", name)
+}
+
+// mergeValues merges the override values over the values, like helm does
+// for successive values files
+func mergeValues(values, override map[string]interface{}) map[string]interface{} {
+	for k, v := range override {
+		if m, ok := v.(map[string]interface{}); ok {
+			if current, ok := values[k].(map[string]interface{}); ok {
+				values[k] = mergeValues(current, m)
+				continue
+			}
+		}
+		values[k] = v
+	}
+	return values
+}
+
+// Uninstall uninstalls the release, waiting for its resources to be deleted
+// if wait is true
+func (h *Helm) Uninstall(_ context.Context, namespace, release string, wait bool) error {
+	cfg, err := h.configuration(h.namespace(namespace))
+	if err != nil {
+		return err
+	}
+
+	uninstall := action.NewUninstall(cfg)
+	uninstall.Wait = wait
+	uninstall.Timeout = h.timeout(0)
+	_, err = uninstall.Run(release)
+	return errors.Wrapf(err, "helm uninstall of %s", release)
+}
+
+// Rollback rolls the release back to the revision, the previous revision
+// if revision is 0
+func (h *Helm) Rollback(_ context.Context, namespace, release string, revision int, wait bool) error {
+	cfg, err := h.configuration(h.namespace(namespace))
+	if err != nil {
+		return err
+	}
+
+	rollback := action.NewRollback(cfg)
+	rollback.Version = revision
+	rollback.Wait = wait
+	rollback.Timeout = h.timeout(0)
+	return errors.Wrapf(rollback.Run(release), "helm rollback of %s", release)
+}
+
+// Status returns the release
+func (h *Helm) Status(_ context.Context, namespace, release string) (*Release, error) {
+	cfg, err := h.configuration(h.namespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := action.NewStatus(cfg).Run(release)
+	if err != nil {
+		return nil, errors.Wrapf(err, "helm status of %s", release)
+	}
+	return releaseOf(r), nil
+}
+
+// GetValues returns the values supplied to the release, with the computed
+// values of the chart if all is true
+func (h *Helm) GetValues(_ context.Context, namespace, release string, all bool) (Values, error) {
+	cfg, err := h.configuration(h.namespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	get := action.NewGetValues(cfg)
+	get.AllValues = all
+	vals, err := get.Run(release)
+	if err != nil {
+		return nil, errors.Wrapf(err, "helm get values of %s", release)
+	}
+	if vals == nil {
+		return Values{}, nil
+	}
+	return Values(vals), nil
+}
+
+// List returns the deployed and failed releases of the namespace, of all
+// the namespaces if the namespace is empty
+func (h *Helm) List(_ context.Context, namespace string) ([]ReleaseSummary, error) {
+	cfg, err := h.configuration(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	list := action.NewList(cfg)
+	list.AllNamespaces = namespace == ""
+	list.SetStateMask()
+	items, err := list.Run()
+	if err != nil {
+		return nil, errors.Wrap(err, "helm list")
+	}
+
+	releases := make([]ReleaseSummary, 0, len(items))
+	for _, item := range items {
+		r := releaseOf(item)
+		releases = append(releases, ReleaseSummary{
+			Name:       r.Name,
+			Namespace:  r.Namespace,
+			Revision:   r.Revision,
+			Status:     r.Status,
+			Chart:      r.Chart + "-" + r.ChartVersion,
+			AppVersion: r.AppVersion,
+			Updated:    r.Updated,
+		})
+	}
+	return releases, nil
+}
+
+// releaseOf maps the release of the Helm SDK
+func releaseOf(r *helmrelease.Release) *Release {
+	release := &Release{
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Revision:  r.Version,
+		Values:    Values(r.Config),
+		Manifest:  r.Manifest,
+	}
+	if r.Info != nil {
+		release.Status = r.Info.Status.String()
+		release.Description = r.Info.Description
+		release.Notes = r.Info.Notes
+		release.Updated = r.Info.LastDeployed.Time
+	}
+	if r.Chart != nil && r.Chart.Metadata != nil {
+		release.Chart = r.Chart.Metadata.Name
+		release.ChartVersion = r.Chart.Metadata.Version
+		release.AppVersion = r.Chart.Metadata.AppVersion
+	}
+	return release
+}
@@ -0,0 +1,207 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+const chartValues = `replicaCount: 1
+image:
+  repository: rancher/elemental-operator
+  tag: v1.6.0
+`
+
+const chartTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  replicas: {{ .Values.replicaCount | quote }}
+  image: {{ .Values.image.repository }}:{{ .Values.image.tag }}
+`
+
+// writeChart writes a local elemental-operator chart of the version, with
+// the CRDs manifest if not empty, and returns its directory
+func writeChart(version, crds string) string {
+	dir := filepath.Join(GinkgoT().TempDir(), "elemental-operator")
+	files := map[string]string{
+		"Chart.yaml":            "apiVersion: v2\nname: elemental-operator\nversion: " + version + "\nappVersion: " + version + "\n",
+		"values.yaml":           chartValues,
+		"templates/config.yaml": chartTemplate,
+		"templates/NOTES.txt":   "{{ .Chart.Name }} installed",
+		"crds/crds.yaml":        crds,
+	}
+	for name, data := range files {
+		if data == "" {
+			continue
+		}
+		Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600)).To(Succeed())
+	}
+	return dir
+}
+
+// newMemoryHelm returns a Helm client storing the releases in memory, the
+// resources of the releases are printed instead of being created
+func newMemoryHelm(k *Kubectl) *Helm {
+	dir := GinkgoT().TempDir()
+	mem := driver.NewMemory()
+
+	helm := k.Helm()
+	helm.Settings.RepositoryConfig = filepath.Join(dir, "repositories.yaml")
+	helm.Settings.RepositoryCache = filepath.Join(dir, "cache")
+	helm.Configuration = func(namespace string) (*action.Configuration, error) {
+		mem.SetNamespace(namespace)
+		return &action.Configuration{
+			Releases:     storage.Init(mem),
+			KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(string, ...interface{}) {},
+		}, nil
+	}
+	return helm
+}
+
+var _ = Describe("helm", func() {
+	var (
+		k     *Kubectl
+		helm  *Helm
+		chart string
+	)
+
+	BeforeEach(func() {
+		k = New()
+		k.PollTimeout = 5 * time.Minute
+		helm = newMemoryHelm(k)
+		chart = writeChart("1.7.0", "")
+	})
+
+	It("installs charts with typed values", func() {
+		valuesFile := filepath.Join(GinkgoT().TempDir(), "values.yaml")
+		Expect(os.WriteFile(valuesFile, []byte("image:\n  repository: registry.suse.com/elemental-operator\n  tag: v1.6.1\n"), 0o600)).To(Succeed())
+
+		values := Values{"replicaCount": 2}.Set("image.tag", "v1.7.0")
+		release, err := helm.Install(context.Background(), "elemental-operator", chart, HelmOptions{
+			Namespace:       "cattle-elemental-system",
+			CreateNamespace: true,
+			Values:          values,
+			ValuesFiles:     []string{valuesFile},
+			Wait:            true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Name).To(Equal("elemental-operator"))
+		Expect(release.Namespace).To(Equal("cattle-elemental-system"))
+		Expect(release.Revision).To(Equal(1))
+		Expect(release.Status).To(Equal("deployed"))
+		Expect(release.ChartVersion).To(Equal("1.7.0"))
+		Expect(release.Notes).To(Equal("elemental-operator installed"))
+		Expect(release.Updated.IsZero()).To(BeFalse())
+		Expect(release.Values).To(HaveKeyWithValue("replicaCount", BeNumerically("==", 2)))
+		Expect(release.Manifest).To(And(
+			ContainSubstring(`replicas: "2"`),
+			ContainSubstring("image: registry.suse.com/elemental-operator:v1.7.0"),
+		))
+	})
+
+	It("upgrades, rolls back and uninstalls releases", func() {
+		ctx := context.Background()
+		release, err := helm.Upgrade(ctx, "elemental-operator", chart, HelmOptions{Install: true, Values: Values{"replicaCount": 2}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Revision).To(Equal(1))
+
+		release, err = helm.Upgrade(ctx, "elemental-operator", writeChart("1.8.0", ""), HelmOptions{ReuseValues: true, Timeout: time.Minute, Wait: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Revision).To(Equal(2))
+		Expect(release.ChartVersion).To(Equal("1.8.0"))
+		Expect(release.Manifest).To(ContainSubstring(`replicas: "2"`))
+
+		Expect(helm.Rollback(ctx, "", "elemental-operator", 0, false)).To(Succeed())
+		release, err = helm.Status(ctx, "", "elemental-operator")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Revision).To(Equal(3))
+		Expect(release.ChartVersion).To(Equal("1.7.0"))
+
+		Expect(helm.Uninstall(ctx, "", "elemental-operator", true)).To(Succeed())
+		_, err = helm.Status(ctx, "", "elemental-operator")
+		Expect(err).To(MatchError(ContainSubstring("helm status of elemental-operator: release: not found")))
+	})
+
+	It("returns the status, values and list of releases", func() {
+		ctx := context.Background()
+		_, err := helm.Install(ctx, "elemental-operator", chart, HelmOptions{Namespace: "cattle-elemental-system", Values: Values{"replicaCount": 2}})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = helm.Install(ctx, "rancher", chart, HelmOptions{Namespace: "cattle-system"})
+		Expect(err).ToNot(HaveOccurred())
+
+		values, err := helm.GetValues(ctx, "cattle-elemental-system", "elemental-operator", false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values).To(HaveLen(1))
+
+		values, err = helm.GetValues(ctx, "cattle-elemental-system", "elemental-operator", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values).To(HaveKey("image"))
+
+		releases, err := helm.List(ctx, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(releases).To(HaveLen(2))
+
+		releases, err = helm.List(ctx, "cattle-system")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(releases).To(HaveLen(1))
+		Expect(releases[0].Name).To(Equal("rancher"))
+		Expect(releases[0].Revision).To(Equal(1))
+		Expect(releases[0].Chart).To(Equal("elemental-operator-1.7.0"))
+		Expect(releases[0].Updated.IsZero()).To(BeFalse())
+	})
+
+	It("manages repositories and reports errors", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("apiVersion: v1\nentries: {}\n"))
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		Expect(helm.RepoAdd(ctx, "rancher-stable", server.URL)).To(Succeed())
+		Expect(helm.RepoAdd(ctx, "rancher-stable", server.URL)).To(Succeed())
+		f, err := repo.LoadFile(helm.Settings.RepositoryConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Repositories).To(HaveLen(1))
+		Expect(filepath.Join(helm.Settings.RepositoryCache, "rancher-stable-index.yaml")).To(BeAnExistingFile())
+
+		Expect(helm.RepoUpdate(ctx)).To(Succeed())
+		Expect(helm.RepoUpdate(ctx, "missing")).To(MatchError("no repository named missing"))
+
+		_, err = helm.Status(ctx, "default", "missing")
+		Expect(err).To(MatchError(ContainSubstring("helm status of missing: release: not found")))
+	})
+})
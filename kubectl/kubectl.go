@@ -22,10 +22,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
+	"regexp"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/onsi/gomega"
@@ -33,6 +34,9 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 
 	wait "github.com/rancher-sandbox/ele-testhelpers/helpers"
 )
@@ -48,6 +52,19 @@ type Kubectl struct {
 	Namespace    string
 	PollTimeout  time.Duration
 	PollInterval time.Duration
+	// Kubeconfig is the kubeconfig file to use, KUBECONFIG or the default
+	// kubeconfig is used if empty
+	Kubeconfig string
+	// Context is the kubeconfig context to use, the current context is used if empty
+	Context string
+	// Backend runs the operations, the kubectl binary is used if nil
+	Backend Backend
+
+	clientMu      sync.Mutex
+	clientBackend *ClientBackend
+
+	trackerMu sync.Mutex
+	tracker   *Tracker
 }
 
 // New returns a new Kubectl command
@@ -59,9 +76,60 @@ func New() *Kubectl {
 	}
 }
 
+// NewForKubeconfig returns a new Kubectl command using the given kubeconfig
+// file and context
+func NewForKubeconfig(kubeconfig, context string) *Kubectl {
+	k := New()
+	k.Kubeconfig = kubeconfig
+	k.Context = context
+	return k
+}
+
+// backend returns the backend of the instance
+func (k *Kubectl) backend() Backend {
+	if k.Backend == nil {
+		return &binaryBackend{kubeconfig: k.Kubeconfig, context: k.Context}
+	}
+	return k.Backend
+}
+
+// run runs kubectl with the kubeconfig and context of the instance
+func (k *Kubectl) run(args ...string) ([]byte, error) {
+	return runBinary(kubeCtlCmd, k.args(args...)...)
+}
+
+// args prepends the kubeconfig and context flags of the instance
+func (k *Kubectl) args(args ...string) []string {
+	return kubectlArgs(k.Kubeconfig, k.Context, args...)
+}
+
+// helmArgs prepends the helm kubeconfig and context flags of the instance
+func (k *Kubectl) helmArgs(args ...string) []string {
+	var flags []string
+	if k.Kubeconfig != "" {
+		flags = append(flags, "--kubeconfig", k.Kubeconfig)
+	}
+	if k.Context != "" {
+		flags = append(flags, "--kube-context", k.Context)
+	}
+	return append(flags, args...)
+}
+
+// RESTConfig returns the client-go configuration matching the kubeconfig
+// and context of the instance
+func (k *Kubectl) RESTConfig() (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if k.Kubeconfig != "" {
+		rules.ExplicitPath = k.Kubeconfig
+	}
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: k.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
 // RunCommandWithCheckString runs the command specified helper in the container
 func (k *Kubectl) RunCommandWithCheckString(namespace string, podName string, commandInPod string, result string) error {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "exec", podName, "--", "sh", "-c", commandInPod)
+	out, err := k.run("--namespace", namespace, "exec", podName, "--", "sh", "-c", commandInPod)
 	if err != nil {
 		return err
 	}
@@ -73,227 +141,117 @@ func (k *Kubectl) RunCommandWithCheckString(namespace string, podName string, co
 
 // GetPodNames returns the names of the pods matching the selector
 func (k *Kubectl) GetPodNames(namespace string, selector string) ([]string, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pod",
-		"-l", selector,
-		"-o", "jsonpath={.items[*].metadata.name}")
-	if err != nil {
-		return []string{}, err
-	}
-	names := strings.Split(string(out), " ")
-	var namesR []string
-	for _, n := range names {
-		if n != "" {
-			namesR = append(namesR, n)
-		}
-	}
-
-	return namesR, nil
+	return k.backend().GetPodNames(namespace, selector)
 }
 
-// WaitForNamespaceWithPod blocks until pods matching the selector are available in the specified namespace. It fails after the timeout.
+// WaitForNamespaceWithPod blocks until pods matching the selector are ready in the specified namespace.
+// It fails after the timeout, or as soon as a pod is in an unrecoverable state.
 func (k *Kubectl) WaitForNamespaceWithPod(namespace string, labelName string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.NamespaceWithReadyPod(namespace, labelName)
-	})
+	return k.waitForPodsReady(namespace, labelName)
 }
 
-// NamespaceWithReadyPod returns true if pods by that label are present in the given namespace
+// NamespaceWithReadyPod returns true if pods by that label are present and ready in the given namespace,
+// use PodReadiness to know why they are not
 func (k *Kubectl) NamespaceWithReadyPod(namespace string, labelName string) (bool, error) {
-	pods, err := k.GetPodNames(namespace, labelName)
+	report, err := k.PodReadiness(namespace, labelName)
 	if err != nil {
 		return false, err
 	}
-	for _, p := range pods {
-		e, _ := k.PodStatus(namespace, p)
-		if e == nil || e.ContainerStatuses == nil || len(e.ContainerStatuses) == 0 {
-			return false, nil
-		}
-		for _, s := range e.ContainerStatuses {
-			if !s.Ready {
-				return false, nil
-			}
-			if !s.Started {
-				return false, nil
-			}
-			if s.State.Running == nil {
-				return false, nil
-			}
-		}
-	}
-	return true, nil
+	return report.Ready(), nil
 }
 
 // WaitNamespacePodsDelete blocks until pods are still available in the given namespace. It fails after the timeout.
 func (k *Kubectl) WaitNamespacePodsDelete(namespace string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.checkNamespacePodsDeleted(namespace)
-	})
-}
-
-// checkNamespacePodsDeleted checks if there is no more pod available in the given namespace.
-func (k *Kubectl) checkNamespacePodsDeleted(namespace string) (bool, error) {
-	pods, err := k.GetPodNames(namespace, "")
-	if err != nil {
-		return false, err
-	}
-
-	if len(pods) <= 0 {
-		return true, nil
-	}
-	return false, nil
+	return k.WatchFor(namespace, "pods", WatchOptions{}, noObject)
 }
 
 // WaitForNamespaceDelete blocks while the namespace is available. It fails after the timeout.
 func (k *Kubectl) WaitForNamespaceDelete(namespace string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.checkNamespaceDeleted(namespace)
-	})
-}
-
-// checkNamespaceDeleted checks if the namespace is terminated
-func (k *Kubectl) checkNamespaceDeleted(namespace string) (bool, error) {
-	phase, err := GetData(namespace, "namespace", namespace, `jsonpath={.status.phase}`)
-	if err != nil {
-		return false, err
-	}
-	if string(phase) == "" {
-		return true, nil
-	}
-	return false, nil
+	return k.WatchFor("", "namespaces", WatchOptions{Name: namespace}, noObject)
 }
 
 // WaitForPod blocks until the pod is available. It fails after the timeout.
 func (k *Kubectl) WaitForPod(namespace string, labelName string, podName string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.PodExists(namespace, labelName, podName)
+	return k.WatchFor(namespace, "pods", WatchOptions{LabelSelector: labelName}, func(objs []*unstructured.Unstructured) (bool, error) {
+		for _, obj := range objs {
+			if strings.Contains(obj.GetName(), podName) {
+				return true, nil
+			}
+		}
+		return false, nil
 	})
 }
 
 // WaitForPodDelete blocks while the pod is available. It fails after the timeout.
 func (k *Kubectl) WaitForPodDelete(namespace string, podName string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.checkPodDeleted(namespace, podName)
-	})
-}
-
-// checkPodTerminateLabelFilter checks if the pod status is terminated
-func (k *Kubectl) checkPodDeleted(namespace string, name string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pod", name)
-	msg := string(out)
-	if err != nil {
-		if strings.Contains(msg, "Error from server (NotFound)") {
-			return true, nil
-		}
-		return false, errors.Wrapf(err, "Kubectl get pod '%s' failed: %s", name, msg)
-	}
-	return false, nil
+	return k.WatchFor(namespace, "pods", WatchOptions{Name: podName}, noObject)
 }
 
 // PodExists returns true if the pod by that label is present
 func (k *Kubectl) PodExists(namespace string, labelName string, podName string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pod", "-l", labelName)
+	pods, err := k.GetPodNames(namespace, labelName)
 	if err != nil {
-		return false, errors.Wrapf(err, "Getting pod %s failed. %s", labelName, string(out))
+		return false, errors.Wrapf(err, "Getting pod %s failed", labelName)
 	}
-	if strings.Contains(string(out), podName) {
-		return true, nil
+	for _, p := range pods {
+		if strings.Contains(p, podName) {
+			return true, nil
+		}
 	}
 	return false, nil
 }
 
 // PodStatus returns the status if the pod by that label is present
 func (k *Kubectl) PodStatus(namespace string, podName string) (*PodStatus, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pod", podName, "-o", "json")
-	if err != nil {
-		return nil, errors.Wrapf(err, "Getting pod %s failed. %s", podName, string(out))
-	}
-	var pod Pod
-	err = json.Unmarshal(out, &pod)
-	if err != nil {
-		return nil, errors.Wrapf(err, "Invalid json '%s': %s", string(out), err.Error())
-	}
-	return &pod.Status, nil
+	return k.backend().PodStatus(namespace, podName)
 }
 
 // WaitForService blocks until the service is available. It fails after the timeout.
 func (k *Kubectl) WaitForService(namespace string, serviceName string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.ServiceExists(namespace, serviceName)
-	})
+	return k.WatchFor(namespace, "services", WatchOptions{Name: serviceName}, anyObject)
 }
 
 // ServiceExists returns true if the pod by that name is in state running
 func (k *Kubectl) ServiceExists(namespace string, serviceName string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "service", serviceName)
-	if err != nil {
-		return false, errors.Wrapf(err, "Getting service %s failed. %s", serviceName, string(out))
-	}
-	if strings.Contains(string(out), serviceName) {
-		return true, nil
-	}
-	return false, nil
+	return k.backend().Exists(namespace, "service", serviceName)
 }
 
 // Exists returns true if the resource by that name exists
 func (k *Kubectl) Exists(namespace, resource, name string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", resource, name)
-	if err != nil {
-		return false, errors.Wrapf(err, "Getting %s %s failed. %s", resource, name, string(out))
-	}
-	if strings.Contains(string(out), name) {
-		return true, nil
-	}
-	return false, nil
+	return k.backend().Exists(namespace, resource, name)
 }
 
 // WaitForSecret blocks until the secret is available. It fails after the timeout.
 func (k *Kubectl) WaitForSecret(namespace string, secretName string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.SecretExists(namespace, secretName)
-	})
+	return k.WatchFor(namespace, "secrets", WatchOptions{Name: secretName}, anyObject)
 }
 
 // SecretExists returns true if the pod by that name is in state running
 func (k *Kubectl) SecretExists(namespace string, secretName string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "secret", secretName)
-	if err != nil {
-		if strings.Contains(string(out), "Error from server (NotFound)") {
-			return false, nil
-		}
-		return false, errors.Wrapf(err, "Getting secret %s failed. %s", secretName, string(out))
-	}
-	if strings.Contains(string(out), secretName) {
-		return true, nil
-	}
-	return false, nil
+	return k.backend().Exists(namespace, "secret", secretName)
 }
 
 // WaitForPVC blocks until the pvc is available. It fails after the timeout.
 func (k *Kubectl) WaitForPVC(namespace string, pvcName string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.pvcExists(namespace, pvcName)
-	})
+	return k.WatchFor(namespace, "persistentvolumeclaims", WatchOptions{Name: pvcName}, anyObject)
 }
 
-// pvcExists returns true if the pvc by that name exists
-func (k *Kubectl) pvcExists(namespace string, pvcName string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pvc", pvcName)
-	if err != nil {
-		if strings.Contains(string(out), "no matching resources found") {
+// Wait waits for the condition on the resource ("type/name", or "type" for
+// all the resources of the type) to be True
+func (k *Kubectl) Wait(namespace string, requiredStatus string, resourceName string, customTimeout time.Duration) error {
+	resource, name, _ := strings.Cut(resourceName, "/")
+	opts := WatchOptions{Name: name, Timeout: customTimeout}
+
+	err := k.WatchFor(namespace, resource, opts, func(objs []*unstructured.Unstructured) (bool, error) {
+		if len(objs) == 0 {
 			return false, nil
 		}
-		return false, errors.Wrapf(err, "Getting pvc %s failed. %s", pvcName, string(out))
-	}
-	if strings.Contains(string(out), pvcName) {
+		for _, obj := range objs {
+			if status, _ := conditionStatus(obj, requiredStatus); status != "True" {
+				return false, nil
+			}
+		}
 		return true, nil
-	}
-	return false, nil
-}
-
-// Wait waits for the condition on the resource using kubectl command
-func (k *Kubectl) Wait(namespace string, requiredStatus string, resourceName string, customTimeout time.Duration) error {
-	err := wait.PollImmediate(k.PollInterval, customTimeout, func() (bool, error) {
-		return k.checkWait(namespace, requiredStatus, resourceName)
 	})
 
 	if err != nil {
@@ -303,19 +261,6 @@ func (k *Kubectl) Wait(namespace string, requiredStatus string, resourceName str
 	return nil
 }
 
-// checkWait check's if the condition is satisfied
-func (k *Kubectl) checkWait(namespace string, requiredStatus string, resourceName string) (bool, error) {
-	cmd := exec.Command("kubectl", "--namespace", namespace, "wait", "--for=condition="+requiredStatus, resourceName, "--timeout=60s")
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		if strings.Contains(string(out), "Error from server (NotFound)") {
-			return false, nil
-		}
-		return false, errors.Wrapf(err, "Kubectl wait failed for %s with status %s. %s", resourceName, requiredStatus, string(out))
-	}
-	return true, nil
-}
-
 // WaitLabelFilter waits for the condition on the resource based on label using kubectl command
 func (k *Kubectl) WaitLabelFilter(namespace string, requiredStatus string, resourceName string, labelName string) error {
 	if requiredStatus == "complete" {
@@ -331,12 +276,12 @@ func (k *Kubectl) WaitLabelFilter(namespace string, requiredStatus string, resou
 			return k.checkPodReadyLabelFilter(namespace, resourceName, labelName, requiredStatus)
 		})
 	}
-	return nil
+	return errors.Errorf("unknown status %s, expected complete, terminate or ready, use WaitUntil for other conditions", requiredStatus)
 }
 
 // checkPodReadyLabelFilter checks is the pod status is completed
 func (k *Kubectl) checkPodReadyLabelFilter(namespace string, resourceName string, labelName string, requiredStatus string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "wait", resourceName, "-l", labelName, "--for=condition="+requiredStatus)
+	out, err := k.run("--namespace", namespace, "wait", resourceName, "-l", labelName, "--for=condition="+requiredStatus)
 	if strings.Contains(string(out), "no matching resources found") {
 		return false, nil
 	}
@@ -349,7 +294,7 @@ func (k *Kubectl) checkPodReadyLabelFilter(namespace string, resourceName string
 // checkPodCompleteLabelFilter checks is the pod status is completed
 func (k *Kubectl) checkPodCompleteLabelFilter(namespace string, labelName string) (bool, error) {
 	exitCodeTemplate := "go-template=\"{{(index (index .items 0).status.containerStatuses 0).state.terminated.exitCode}}\""
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pod", "-l", labelName, "-o", exitCodeTemplate)
+	out, err := k.run("--namespace", namespace, "get", "pod", "-l", labelName, "-o", exitCodeTemplate)
 	if err != nil {
 		return false, nil
 	}
@@ -361,7 +306,7 @@ func (k *Kubectl) checkPodCompleteLabelFilter(namespace string, labelName string
 
 // checkPodTerminateLabelFilter checks is the pod status is terminated
 func (k *Kubectl) checkPodTerminateLabelFilter(namespace string, labelName string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "pod", "-l", labelName)
+	out, err := k.run("--namespace", namespace, "get", "pod", "-l", labelName)
 	if err != nil {
 		return false, errors.Wrapf(err, "Kubectl get pod failed with label %s failed. %s", labelName, string(out))
 
@@ -374,32 +319,26 @@ func (k *Kubectl) checkPodTerminateLabelFilter(namespace string, labelName strin
 
 // CreateRoleBinding Create a new rolebinding in a namespace from a cluster role
 func (k *Kubectl) CreateRoleBinding(namespace string, clusterrole, serviceaccount, role string) error {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "create", "rolebinding", "--clusterrole", clusterrole, "--serviceaccount", serviceaccount, role)
-	if err != nil {
-		return errors.Wrapf(err, "Kubectl create rolebinding failed with role %s failed. %s", clusterrole, string(out))
-
+	if err := k.backend().CreateRoleBinding(namespace, clusterrole, serviceaccount, role); err != nil {
+		return err
 	}
-
+	k.record(ObjectRef{GroupVersionKind: roleBindingKind, Namespace: namespace, Name: role})
 	return nil
 }
 
 // CreateServiceAccount Create a new serviceaccount in a namespace
 func (k *Kubectl) CreateServiceAccount(namespace string, serviceaccount string) error {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "create", "serviceaccount", serviceaccount)
-	if err != nil {
-		return errors.Wrapf(err, "Kubectl create serviceaccount with %s failed. %s", serviceaccount, string(out))
-
+	if err := k.backend().CreateServiceAccount(namespace, serviceaccount); err != nil {
+		return err
 	}
-
+	k.record(ObjectRef{GroupVersionKind: serviceAccountKind, Namespace: namespace, Name: serviceaccount})
 	return nil
 }
 
 // DeleteRoleBinding Deletes a rolebinding in a namespace
 func (k *Kubectl) DeleteRoleBinding(namespace string, role string) error {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "delete", "rolebinding", role)
-	if err != nil {
-		return errors.Wrapf(err, "Kubectl delete rolebinding failed with role %s failed. %s", role, string(out))
-
+	if err := k.backend().DeleteResource(namespace, "rolebinding", role); err != nil {
+		return errors.Wrapf(err, "Kubectl delete rolebinding failed with role %s failed", role)
 	}
 
 	return nil
@@ -407,124 +346,167 @@ func (k *Kubectl) DeleteRoleBinding(namespace string, role string) error {
 
 // DeleteServiceAccount Deletes a serviceaccount in a namespace
 func (k *Kubectl) DeleteServiceAccount(namespace string, serviceaccount string) error {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "delete", "serviceaccount", serviceaccount)
-	if err != nil {
-		return errors.Wrapf(err, "Kubectl delete serviceaccount with %s failed. %s", serviceaccount, string(out))
-
+	if err := k.backend().DeleteResource(namespace, "serviceaccount", serviceaccount); err != nil {
+		return errors.Wrapf(err, "Kubectl delete serviceaccount with %s failed", serviceaccount)
 	}
 
 	return nil
 }
 
-// CreateNamespace create the namespace using kubectl command
-func CreateNamespace(name string) error {
-	_, err := runBinary(kubeCtlCmd, "create", "namespace", name)
-	if err != nil {
-		return errors.Wrapf(err, "Deleting namespace %s failed", name)
+// CreateNamespace creates the namespace
+func (k *Kubectl) CreateNamespace(name string) error {
+	if err := k.backend().CreateNamespace(name); err != nil {
+		return err
 	}
+	k.record(ObjectRef{GroupVersionKind: namespaceKind, Name: name})
 	return nil
 }
 
+// CreateNamespace create the namespace using kubectl command
+func CreateNamespace(name string) error {
+	return New().CreateNamespace(name)
+}
+
 // DeleteNamespace removes existing ns
-func DeleteNamespace(ns string) error {
+func (k *Kubectl) DeleteNamespace(ns string) error {
 	fmt.Printf("Cleaning up namespace %s \n", ns)
 
-	_, err := runBinary(kubeCtlCmd, "delete", "--wait=false", "--ignore-not-found", "--grace-period=30", "namespace", ns)
-	if err != nil {
-		return errors.Wrapf(err, "Deleting namespace %s failed", ns)
-	}
+	return k.backend().DeleteNamespace(ns)
+}
 
-	return nil
+// DeleteNamespace removes existing ns
+func DeleteNamespace(ns string) error {
+	return New().DeleteNamespace(ns)
 }
 
-// Create creates the resource using kubectl command
-func Create(namespace string, yamlFilePath string) error {
-	_, err := runBinary(kubeCtlCmd, "--namespace", namespace, "create", "-f", yamlFilePath)
-	if err != nil {
+// CreateFile creates the resources of a manifest file, directory or URL
+func (k *Kubectl) CreateFile(namespace string, yamlFilePath string) error {
+	if err := k.manifestFile("create", namespace, yamlFilePath, Backend.Create); err != nil {
 		return errors.Wrapf(err, "creating yaml spec %s failed", yamlFilePath)
 	}
 	return nil
 }
 
-// CreateSecretFromLiteral creates a generic type secret using kubectl command
-func CreateSecretFromLiteral(namespace string, secretName string, literalValues map[string]string) error {
-	args := []string{"--namespace", namespace, "create", "secret", "generic", secretName}
-
-	for key, value := range literalValues {
-		args = append(args, fmt.Sprintf("--from-literal=%s=%s", key, value))
+// manifestFile runs the operation on the manifest of the path. The binary
+// backend gets the path as is with -f, like kubectl accepts it, the other
+// backends get the manifest read locally.
+func (k *Kubectl) manifestFile(verb, namespace, path string, op func(b Backend, namespace string, data []byte) error) error {
+	b := k.backend()
+	if bb, ok := b.(*binaryBackend); ok {
+		_, err := bb.run(namespaceArgs(namespace, verb, "-f", path)...)
+		return err
 	}
 
-	_, err := runBinary(kubeCtlCmd, args...)
+	data, err := readManifest(path)
 	if err != nil {
-		return errors.Wrapf(err, "creating secret %s failed from literal value", secretName)
+		return err
+	}
+	return op(b, namespace, data)
+}
+
+// Create creates the resource using kubectl command
+func Create(namespace string, yamlFilePath string) error {
+	return New().CreateFile(namespace, yamlFilePath)
+}
+
+// CreateSecretFromLiteral creates a generic type secret
+func (k *Kubectl) CreateSecretFromLiteral(namespace string, secretName string, literalValues map[string]string) error {
+	if err := k.backend().CreateSecretFromLiteral(namespace, secretName, literalValues); err != nil {
+		return err
 	}
+	k.record(ObjectRef{GroupVersionKind: secretKind, Namespace: namespace, Name: secretName})
 	return nil
 }
 
+// CreateSecretFromLiteral creates a generic type secret using kubectl command
+func CreateSecretFromLiteral(namespace string, secretName string, literalValues map[string]string) error {
+	return New().CreateSecretFromLiteral(namespace, secretName, literalValues)
+}
+
+// DeleteSecret deletes the secret, a missing secret is not an error
+func (k *Kubectl) DeleteSecret(namespace string, secretName string) error {
+	return k.backend().DeleteResource(namespace, "secret", secretName)
+}
+
 // DeleteSecret deletes the namespace using kubectl command
 func DeleteSecret(namespace string, secretName string) error {
-	_, err := runBinary(kubeCtlCmd, "--namespace", namespace, "delete", "secret", secretName, "--ignore-not-found")
-	return err
+	return New().DeleteSecret(namespace, secretName)
+}
+
+// ApplyFile updates the resources of a manifest file, directory or URL
+func (k *Kubectl) ApplyFile(namespace string, yamlFilePath string) error {
+	return k.manifestFile("apply", namespace, yamlFilePath, Backend.Apply)
 }
 
 // Apply updates the resource using kubectl command
 func Apply(namespace string, yamlFilePath string) error {
-	_, err := runBinary(kubeCtlCmd, "--namespace", namespace, "apply", "-f", yamlFilePath)
-	return err
+	return New().ApplyFile(namespace, yamlFilePath)
+}
+
+// PatchNamespace patches the namespace resource with a JSON patch
+func (k *Kubectl) PatchNamespace(name string, patch string) error {
+	return k.backend().PatchNamespace(name, patch)
 }
 
 // PatchNamespace patche the namespace resource using kubectl command
 func PatchNamespace(name string, patch string) error {
-	_, err := runBinary(kubeCtlCmd,
-		"patch", "namespace", name,
-		"--type=json", "-p", patch)
-	return err
+	return New().PatchNamespace(name, patch)
+}
+
+// DeleteFile deletes the resources of a manifest file, directory or URL
+func (k *Kubectl) DeleteFile(namespace string, yamlFilePath string) error {
+	return k.manifestFile("delete", namespace, yamlFilePath, Backend.Delete)
 }
 
 // Delete creates the resource using kubectl command
 func Delete(namespace string, yamlFilePath string) error {
-	_, err := runBinary(kubeCtlCmd, "--namespace", namespace, "delete", "-f", yamlFilePath)
-	return err
+	return New().DeleteFile(namespace, yamlFilePath)
+}
+
+// DeleteResource deletes the resource, a missing resource is not an error
+func (k *Kubectl) DeleteResource(namespace string, resourceName string, name string) error {
+	return k.backend().DeleteResource(namespace, resourceName, name)
 }
 
 // DeleteResource deletes the resource using kubectl command
 func DeleteResource(namespace string, resourceName string, name string) error {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "delete", resourceName, name)
-	if err != nil {
-		if strings.Contains(string(out), "Error from server (NotFound)") {
-			return nil
-		}
-		return errors.Wrapf(err, "deleting resource %s failed %s", resourceName, string(out))
-	}
-	return nil
+	return New().DeleteResource(namespace, resourceName, name)
+}
+
+// DeleteLabelFilter deletes the resource based on label
+func (k *Kubectl) DeleteLabelFilter(namespace string, resourceName string, labelName string) error {
+	return k.backend().DeleteLabelFilter(namespace, resourceName, labelName)
 }
 
 // DeleteLabelFilter deletes the resource based on label using kubectl command
 func DeleteLabelFilter(namespace string, resourceName string, labelName string) error {
-	_, err := runBinary(kubeCtlCmd, "--namespace", namespace, "delete", resourceName, "-l", labelName)
-	if err != nil {
-		return errors.Wrapf(err, "deleting resource %s with label %s failed", resourceName, labelName)
-	}
-	return nil
+	return New().DeleteLabelFilter(namespace, resourceName, labelName)
 }
 
 // SecretCheckData checks the field specified in the given field
-func SecretCheckData(namespace string, secretName string, fieldPath string) error {
+func (k *Kubectl) SecretCheckData(namespace string, secretName string, fieldPath string) error {
 	fetchCommand := "go-template=\"{{" + fieldPath + "}}\""
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", "secret", secretName, "-o", fetchCommand)
+	_, err := k.backend().GetData(namespace, "secret", secretName, fetchCommand)
 	if err != nil {
-		return errors.Wrapf(err, "Getting secret %s with go template %s failed. %s", secretName, fieldPath, string(out))
-	}
-	if len(string(out)) > 0 {
-		return nil
+		return errors.Wrapf(err, "Getting secret %s with go template %s failed", secretName, fieldPath)
 	}
 	return nil
 }
 
-// RunCommandWithOutput runs the command specified in the container and returns output
-func RunCommandWithOutput(namespace string, podName string, commandInPod string) (string, error) {
-	kubectlCommand := "kubectl --namespace " + namespace + " exec -it " + podName + " " + commandInPod
-	cmd := exec.Command("bash", "-c", kubectlCommand)
+// SecretCheckData checks the field specified in the given field
+func SecretCheckData(namespace string, secretName string, fieldPath string) error {
+	return New().SecretCheckData(namespace, secretName, fieldPath)
+}
+
+// RunCommandWithOutput runs the command specified in the container and returns output,
+// the command is appended to kubectl exec and interpreted by bash, e.g.
+// "-c container -- sh -c 'ls | wc -l'"
+func (k *Kubectl) RunCommandWithOutput(namespace string, podName string, commandInPod string) (string, error) {
+	var args []string
+	for _, arg := range k.args("--namespace", namespace, "exec", podName) {
+		args = append(args, shellQuote(arg))
+	}
+	cmd := exec.Command("bash", "-c", kubeCtlCmd+" "+strings.Join(args, " ")+" "+commandInPod)
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
@@ -539,67 +521,39 @@ func RunCommandWithOutput(namespace string, podName string, commandInPod string)
 	return "", err
 }
 
+// RunCommandWithOutput runs the command specified in the container and returns output
+func RunCommandWithOutput(namespace string, podName string, commandInPod string) (string, error) {
+	return New().RunCommandWithOutput(namespace, podName, commandInPod)
+}
+
 // WaitForData blocks until the specified data is available. It fails after the timeout.
 func (k *Kubectl) WaitForData(namespace string, resourceName string, name string, template string, expectation string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		result, err := GetData(namespace, resourceName, name, template)
+	return k.WatchFor(namespace, resourceName, WatchOptions{Name: name}, func(objs []*unstructured.Unstructured) (bool, error) {
+		if len(objs) == 0 {
+			return false, nil
+		}
+
+		result, err := formatObject(objs[0].Object, template)
 		if err != nil {
 			return false, err
 		}
-		if strings.Contains(string(result), expectation) {
-			return true, nil
-		}
-		return false, nil
+		return strings.Contains(string(result), expectation), nil
 	})
 }
 
-func writeTemporaryYAML(v interface{}) (string, error) {
-	tmpfile, err := os.CreateTemp(os.TempDir(), "yaml-")
-	if err != nil {
-		return "", err
-	}
-
-	content, err := yaml.Marshal(v)
-	if err != nil {
-		return "", err
-	}
-	if _, err := tmpfile.Write(content); err != nil {
-		return "", err
-	}
-	if err := tmpfile.Close(); err != nil {
-		return "", err
-	}
-
-	return tmpfile.Name(), nil
-}
-
-func writeTemporaryJSON(v interface{}) (string, error) {
-	tmpfile, err := os.CreateTemp(os.TempDir(), "json-")
-	if err != nil {
-		return "", err
-	}
-
-	content, err := json.Marshal(v)
+// GetObject decodes the resource into obj
+func (k *Kubectl) GetObject(name, namespace, resourceType string, obj interface{}) error {
+	r, err := k.GetData(namespace, resourceType, name, "json")
 	if err != nil {
-		return "", err
-	}
-	if _, err := tmpfile.Write(content); err != nil {
-		return "", err
-	}
-	if err := tmpfile.Close(); err != nil {
-		return "", err
+		return err
 	}
 
-	return tmpfile.Name(), nil
+	return json.Unmarshal(r, obj)
 }
 
+// GetObject decodes the resource into obj
 func GetObject(name, namespace, resourceType string, obj interface{}) (err error) {
-	r, err := GetData(namespace, resourceType, name, `jsonpath={}`)
-	if err != nil {
-		return err
-	}
-
-	return json.Unmarshal(r, obj)
+	return New().GetObject(name, namespace, resourceType, obj)
 }
 
 // EventuallyPodMatch uses ginkgo/gomega matcher to satisfy against a namespace/label pod
@@ -615,19 +569,12 @@ func (k *Kubectl) EventuallyPodMatch(namespace, label string, timeout, poll time
 
 // ApplyYAML applies arbitrary interfaces with kubectl.
 func (k *Kubectl) ApplyYAML(namespace string, name string, v interface{}) error {
-	path, err := writeTemporaryYAML(v)
+	content, err := yaml.Marshal(v)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(path)
 
-	ops := []string{"apply", "-f", path}
-	if namespace != "" {
-		ops = append([]string{"--namespace", namespace}, ops...)
-	}
-
-	_, err = runBinary(kubeCtlCmd, ops...)
-	if err != nil {
+	if err := k.backend().Apply(namespace, content); err != nil {
 		return errors.Wrapf(err, "Applying resource %s. %s", name, v)
 	}
 
@@ -636,19 +583,12 @@ func (k *Kubectl) ApplyYAML(namespace string, name string, v interface{}) error
 
 // ApplyJSON applies arbitrary interfaces with kubectl.
 func (k *Kubectl) ApplyJSON(namespace string, name string, v interface{}) error {
-	path, err := writeTemporaryJSON(v)
+	content, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(path)
 
-	ops := []string{"apply", "-f", path}
-	if namespace != "" {
-		ops = append([]string{"--namespace", namespace}, ops...)
-	}
-
-	_, err = runBinary(kubeCtlCmd, ops...)
-	if err != nil {
+	if err := k.backend().Apply(namespace, content); err != nil {
 		return errors.Wrapf(err, "Applying resource %s. %s", name, v)
 	}
 
@@ -657,7 +597,7 @@ func (k *Kubectl) ApplyJSON(namespace string, name string, v interface{}) error
 
 // Delete calls kubectl with the given arguments
 func (k *Kubectl) Delete(args ...string) error {
-	if _, err := runBinary(kubeCtlCmd, append([]string{"delete"}, args...)...); err != nil {
+	if _, err := k.run(append([]string{"delete"}, args...)...); err != nil {
 		return errors.Wrapf(err, "Deleting resource: %s", args)
 	}
 
@@ -666,14 +606,22 @@ func (k *Kubectl) Delete(args ...string) error {
 
 // WaitForDaemonSet blocks until daemonset matching the label is available in the specified namespace. It fails after the timeout.
 func (k *Kubectl) WaitForDaemonSet(namespace string, label string) error {
-	return wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
-		return k.DaemonSetReady(namespace, label)
+	return k.WatchFor(namespace, "daemonsets.apps", WatchOptions{LabelSelector: label}, func(objs []*unstructured.Unstructured) (bool, error) {
+		if len(objs) == 0 {
+			return false, nil
+		}
+		for _, obj := range objs {
+			if !daemonSetRolledOut(obj) {
+				return false, nil
+			}
+		}
+		return true, nil
 	})
 }
 
 // DaemonSetReady returns true if daemontset by that label is present in the given namespace
 func (k *Kubectl) DaemonSetReady(namespace string, label string) (bool, error) {
-	out, err := runBinary(kubeCtlCmd, "rollout", "status", "daemonset", "--namespace", namespace, "--selector", label)
+	out, err := k.run("rollout", "status", "daemonset", "--namespace", namespace, "--selector", label)
 	if err == nil {
 		if !strings.Contains(string(out), "successfully rolled out") {
 			return false, err
@@ -697,7 +645,7 @@ type ConfigMap struct {
 // GetConfigMap blocks until the specified data is available. It fails after the timeout.
 func (k *Kubectl) GetConfigMap(namespace string, name string) (ConfigMap, error) {
 	var cfgmap ConfigMap
-	out, err := GetData(namespace, "configmap", name, "json")
+	out, err := k.backend().GetData(namespace, "configmap", name, "json")
 	if err != nil {
 		return cfgmap, err
 	}
@@ -710,65 +658,58 @@ func (k *Kubectl) GetConfigMap(namespace string, name string) (ConfigMap, error)
 	return cfgmap, nil
 }
 
+// GetData fetches the specified output by the given templatePath
+func (k *Kubectl) GetData(namespace string, resourceName string, name string, templatePath string) ([]byte, error) {
+	return k.backend().GetData(namespace, resourceName, name, templatePath)
+}
+
 // GetData fetches the specified output by the given templatePath
 func GetData(namespace string, resourceName string, name string, templatePath string) ([]byte, error) {
-	out, err := runBinary(kubeCtlCmd, "--namespace", namespace, "get", resourceName, name, "-o", templatePath)
-	msg := string(out)
-	if err != nil {
-		if strings.Contains(msg, "Error from server (NotFound)") {
-			return []byte{}, nil
-		}
-		return []byte{}, errors.Wrapf(err, "getting  %s failed with template Path %s", name, templatePath)
-	}
-	if len(string(out)) > 0 {
-		return out, nil
-	}
-	return []byte{}, errors.Wrapf(err, "output is empty for %s with template Path %s", name, templatePath)
+	return New().GetData(namespace, resourceName, name, templatePath)
+}
+
+// Run allow to control kubectl directly
+func (k *Kubectl) Run(s ...string) (string, error) {
+	out, err := k.run(s...)
+	return string(out), err
 }
 
 // Run allow to control kubectl directly
 func Run(s ...string) (string, error) {
-	out, err := runBinary(kubeCtlCmd, s...)
-	msg := string(out)
-	return msg, err
+	return New().Run(s...)
+}
+
+// RunWithoutErr allow to control kubectl directly but without any StdErr
+func (k *Kubectl) RunWithoutErr(s ...string) (string, error) {
+	out, err := runBinaryWithoutErr(kubeCtlCmd, k.args(s...)...)
+	return string(out), err
 }
 
 // RunWithoutErr allow to control kubectl directly but without any StdErr
 func RunWithoutErr(s ...string) (string, error) {
-	out, err := runBinaryWithoutErr(kubeCtlCmd, s...)
-	msg := string(out)
-	return msg, err
+	return New().RunWithoutErr(s...)
 }
 
 // GetCRDs returns all CRDs
-func GetCRDs() (*ClusterCrd, error) {
-	customResource := &ClusterCrd{}
-	stdOutput, err := runBinary(kubeCtlCmd, "get", "crds", "-o=json")
-	if err != nil {
-		return customResource, err
-	}
-
-	d := json.NewDecoder(bytes.NewReader(stdOutput))
-	if err := d.Decode(customResource); err != nil {
-		return customResource, err
-	}
+func (k *Kubectl) GetCRDs() (*ClusterCrd, error) {
+	return k.backend().GetCRDs()
+}
 
-	return customResource, nil
+// GetCRDs returns all CRDs
+func GetCRDs() (*ClusterCrd, error) {
+	return New().GetCRDs()
 }
 
-// DeleteWebhooks removes existing webhookconfiguration and validatingwebhookconfiguration
-func DeleteWebhooks(ns string, name string) error {
+// DeleteWebhooks removes existing webhookconfiguration and validatingwebhookconfiguration,
+// see RemoveWebhooks to select them by label or service and restore them after the spec
+func (k *Kubectl) DeleteWebhooks(ns string, name string) error {
 	var messages string
 	webHookName := fmt.Sprintf("%s-%s", name, ns)
 
-	_, err := runBinary(kubeCtlCmd, "delete", "--ignore-not-found", "mutatingwebhookconfiguration", webHookName)
-	if err != nil {
-		messages = fmt.Sprintf("%v%v\n", messages, err.Error())
-	}
-
-	_, err = runBinary(kubeCtlCmd, "delete", "--ignore-not-found", "validatingwebhookconfiguration", webHookName)
-	if err != nil {
-		messages = fmt.Sprintf("%v%v\n", messages, err.Error())
+	for _, resource := range []string{"mutatingwebhookconfiguration", "validatingwebhookconfiguration"} {
+		if err := k.backend().DeleteResource("", resource, webHookName); err != nil {
+			messages = fmt.Sprintf("%v%v\n", messages, err.Error())
+		}
 	}
 
 	if messages != "" {
@@ -777,33 +718,43 @@ func DeleteWebhooks(ns string, name string) error {
 	return nil
 }
 
-// HelmBinaryVersion executes helm version and return 2 or 3
+// DeleteWebhooks removes existing webhookconfiguration and validatingwebhookconfiguration
+func DeleteWebhooks(ns string, name string) error {
+	return New().DeleteWebhooks(ns, name)
+}
+
+// helmMajorVersion matches the major version in the output of helm version
+var helmMajorVersion = regexp.MustCompile(`Version:"v(\d+)\.`)
+
+// HelmBinaryVersion executes helm version and returns its major version,
+// e.g. 3, see Helm.Version for the full version
 func HelmBinaryVersion() (string, error) {
 	out, err := runBinary(helmCmd, "version")
 	if err != nil {
 		return "", err
 	}
 
-	if strings.Contains(string(out), `SemVer:"v2.`) {
-		return "2", nil
-	}
-	if strings.Contains(string(out), `Version:"v3.`) {
-		return "3", nil
+	if m := helmMajorVersion.FindSubmatch(out); m != nil {
+		return string(m[1]), nil
 	}
 	return "", errors.Errorf("Failed to determine helm binary version: %s", out)
 }
 
+// RunHelmBinaryWithCustomErr executes helm with the kubeconfig and context of the instance
+func (k *Kubectl) RunHelmBinaryWithCustomErr(args ...string) error {
+	_, err := k.RunHelmBinaryWithOutput(args...)
+	return err
+}
+
 // RunHelmBinaryWithCustomErr executes a desire binary
 func RunHelmBinaryWithCustomErr(args ...string) error {
-	out, err := runBinary(helmCmd, args...)
-	if err != nil {
-		return &CustomError{strings.Join(append([]string{helmCmd}, args...), " "), string(out), err}
-	}
-	return nil
+	return New().RunHelmBinaryWithCustomErr(args...)
 }
 
-// RunHelmBinaryWithOutput executes a desired binary and returns the output
-func RunHelmBinaryWithOutput(args ...string) (string, error) {
+// RunHelmBinaryWithOutput executes helm with the kubeconfig and context of
+// the instance and returns the output
+func (k *Kubectl) RunHelmBinaryWithOutput(args ...string) (string, error) {
+	args = k.helmArgs(args...)
 	out, err := runBinary(helmCmd, args...)
 	if err != nil {
 		return string(out), &CustomError{strings.Join(append([]string{helmCmd}, args...), " "), string(out), err}
@@ -811,6 +762,11 @@ func RunHelmBinaryWithOutput(args ...string) (string, error) {
 	return string(out), nil
 }
 
+// RunHelmBinaryWithOutput executes a desired binary and returns the output
+func RunHelmBinaryWithOutput(args ...string) (string, error) {
+	return New().RunHelmBinaryWithOutput(args...)
+}
+
 // runBinary executes a binary cmd and returns the stdOutput and stdError combined
 func runBinary(binaryName string, args ...string) ([]byte, error) {
 	cmd := exec.Command(binaryName, args...)
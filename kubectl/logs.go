@@ -0,0 +1,297 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// LogOptions selects the logs to retrieve
+type LogOptions struct {
+	// Container is the container to read, all the containers of the pod
+	// are read if empty
+	Container string
+	// Previous reads the logs of the previous instance of the container,
+	// e.g. to debug a crash-looping container
+	Previous bool
+	// Since only returns the logs newer than the duration, it cannot be
+	// used with SinceTime
+	Since time.Duration
+	// SinceTime only returns the logs newer than the time
+	SinceTime time.Time
+	// Until only returns the logs older than the time, lines are filtered
+	// on their timestamp
+	Until time.Time
+	// TailLines only returns the last lines, all the lines if zero
+	TailLines int64
+	// Timestamps prefixes each line with its RFC3339 timestamp
+	Timestamps bool
+}
+
+// validate rejects the options the API server does not accept together
+func (o LogOptions) validate() error {
+	if o.Since > 0 && !o.SinceTime.IsZero() {
+		return errors.New("only one of Since and SinceTime can be set")
+	}
+	return nil
+}
+
+// podLogOptions returns the API options of the container
+func (o LogOptions) podLogOptions(container string, follow bool) *corev1.PodLogOptions {
+	opts := &corev1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+		Previous:  o.Previous,
+		// Until is filtered on the timestamps of the lines
+		Timestamps: o.Timestamps || !o.Until.IsZero(),
+	}
+	if o.Since > 0 {
+		opts.SinceSeconds = ptr(int64(o.Since.Seconds()))
+	}
+	if !o.SinceTime.IsZero() {
+		opts.SinceTime = &metav1.Time{Time: o.SinceTime}
+	}
+	if o.TailLines > 0 {
+		opts.TailLines = ptr(o.TailLines)
+	}
+	return opts
+}
+
+// filterLine returns the line as requested, false if it is after Until
+func (o LogOptions) filterLine(line string) (string, bool) {
+	if o.Until.IsZero() {
+		return line, true
+	}
+
+	stamp, rest, found := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339Nano, stamp)
+	if !found || err != nil {
+		return line, true
+	}
+	if t.After(o.Until) {
+		return "", false
+	}
+	if o.Timestamps {
+		return line, true
+	}
+	return rest, true
+}
+
+// GetPodLogs returns the logs of a container of the pod, the container can
+// be omitted if the pod has a single container
+func (k *Kubectl) GetPodLogs(namespace, pod string, opts LogOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+	c, err := k.client()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	stream, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(pod, opts.podLogOptions(opts.Container, false)).Stream(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting logs of pod %s", pod)
+	}
+	defer stream.Close()
+
+	var buf bytes.Buffer
+	if err := copyLogLines(&buf, stream, "", opts); err != nil {
+		return "", errors.Wrapf(err, "reading logs of pod %s", pod)
+	}
+	return buf.String(), nil
+}
+
+// GetLogs returns the logs of the containers of the pods matching the
+// selector, indexed by "pod/container". With Previous, the containers which
+// did not restart are skipped. The logs which could be read are returned
+// with the errors of the other containers.
+func (k *Kubectl) GetLogs(namespace, selector string, opts LogOptions) (map[string]string, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	pods, err := List[corev1.Pod](k, namespace, ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	logs := map[string]string{}
+	for _, pod := range pods {
+		for _, container := range logContainers(&pod, opts) {
+			containerOpts := opts
+			containerOpts.Container = container
+
+			out, err := k.GetPodLogs(pod.Namespace, pod.Name, containerOpts)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "container %s", container))
+				continue
+			}
+			logs[pod.Name+"/"+container] = out
+		}
+	}
+	return logs, utilerrors.NewAggregate(errs)
+}
+
+// FollowPodLogs copies the logs of a container of the pod to w as they are
+// written, until the context is done or the container terminates
+func (k *Kubectl) FollowPodLogs(ctx context.Context, namespace, pod string, opts LogOptions, w io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(pod, opts.podLogOptions(opts.Container, true)).Stream(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "following logs of pod %s", pod)
+	}
+	defer stream.Close()
+
+	err = copyLogLines(w, stream, "", opts)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// StreamLogs follows the logs of the containers of the pods matching the
+// selector during the spec, each line is prefixed by "[pod/container]".
+// The logs are written to w, or to GinkgoWriter if nil, e.g. an os.File to
+// keep them. It must be called from a Ginkgo node, the streams are stopped
+// with DeferCleanup.
+func (k *Kubectl) StreamLogs(namespace, selector string, opts LogOptions, w io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	pods, err := List[corev1.Pod](k, namespace, ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = ginkgo.GinkgoWriter
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &syncWriter{w: w}
+
+	var wg sync.WaitGroup
+	for _, pod := range pods {
+		for _, container := range logContainers(&pod, opts) {
+			containerOpts := opts
+			containerOpts.Container = container
+			prefix := fmt.Sprintf("[%s/%s] ", pod.Name, container)
+
+			wg.Add(1)
+			go func(namespace, name string) {
+				defer wg.Done()
+
+				c, err := k.client()
+				if err != nil {
+					return
+				}
+				stream, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(name, containerOpts.podLogOptions(container, true)).Stream(ctx)
+				if err != nil {
+					fmt.Fprintf(out, "%sfailed to follow logs: %s\n", prefix, err)
+					return
+				}
+				defer stream.Close()
+
+				_ = copyLogLines(out, stream, prefix, containerOpts)
+			}(pod.Namespace, pod.Name)
+		}
+	}
+
+	ginkgo.DeferCleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return nil
+}
+
+// logContainers returns the containers to read, all of them by default
+func logContainers(pod *corev1.Pod, opts LogOptions) []string {
+	if opts.Container != "" {
+		return []string{opts.Container}
+	}
+
+	var containers []string
+	all := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range all {
+		if opts.Previous && !hasPreviousInstance(pod, c.Name) {
+			continue
+		}
+		containers = append(containers, c.Name)
+	}
+	return containers
+}
+
+// hasPreviousInstance returns true if the container terminated at least
+// once, pending or waiting containers which never ran have no previous logs
+func hasPreviousInstance(pod *corev1.Pod, container string) bool {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if s.Name == container {
+			return s.LastTerminationState.Terminated != nil
+		}
+	}
+	return false
+}
+
+// copyLogLines copies the filtered lines of r to w with the prefix
+func copyLogLines(w io.Writer, r io.Reader, prefix string, opts LogOptions) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, ok := opts.filterLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s%s\n", prefix, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// syncWriter serializes the writes of concurrent streams
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
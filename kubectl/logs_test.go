@@ -0,0 +1,113 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// operatorPod returns a pod with an init container and a container
+func operatorPod(name string) *corev1.Pod {
+	pod := readyPod(name)
+	pod.Spec.InitContainers = []corev1.Container{{Name: "init"}}
+	pod.Spec.Containers = []corev1.Container{{Name: "operator"}}
+	return pod
+}
+
+// logOptions returns the options of the log requests made to the clientset
+func logOptions(k *Kubectl) []*corev1.PodLogOptions {
+	var opts []*corev1.PodLogOptions
+	for _, action := range k.Backend.(*ClientBackend).Clientset.(*fake.Clientset).Actions() {
+		if a, ok := action.(k8stesting.GenericActionImpl); ok && a.Subresource == "log" {
+			opts = append(opts, a.Value.(*corev1.PodLogOptions))
+		}
+	}
+	return opts
+}
+
+var _ = Describe("logs", func() {
+	It("gets the logs of a pod", func() {
+		k := newFakeKubectl(operatorPod("operator-1"))
+
+		out, err := k.GetPodLogs("default", "operator-1", LogOptions{Container: "operator", Previous: true, Since: time.Minute})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("fake logs\n"))
+
+		opts := logOptions(k)
+		Expect(opts).To(HaveLen(1))
+		Expect(opts[0].Container).To(Equal("operator"))
+		Expect(opts[0].Previous).To(BeTrue())
+		Expect(*opts[0].SinceSeconds).To(Equal(int64(60)))
+		Expect(opts[0].Follow).To(BeFalse())
+	})
+
+	It("gets the logs of all the containers matching a selector", func() {
+		k := newFakeKubectl(operatorPod("operator-1"), operatorPod("operator-2"))
+
+		logs, err := k.GetLogs("default", "app=test", LogOptions{Until: time.Now()})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logs).To(HaveLen(4))
+		Expect(logs).To(HaveKeyWithValue("operator-1/init", "fake logs\n"))
+		Expect(logs).To(HaveKeyWithValue("operator-2/operator", "fake logs\n"))
+
+		// Until needs the timestamps to filter the lines
+		Expect(logOptions(k)[0].Timestamps).To(BeTrue())
+	})
+
+	It("gets the previous logs of the restarted containers only", func() {
+		restarted := operatorPod("operator-1")
+		restarted.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:         "operator",
+			RestartCount: 1,
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"},
+			},
+		}}
+		pending := operatorPod("operator-2")
+		pending.Status = corev1.PodStatus{Phase: corev1.PodPending}
+		k := newFakeKubectl(restarted, pending)
+
+		logs, err := k.GetLogs("default", "app=test", LogOptions{Previous: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logs).To(HaveLen(1))
+		Expect(logs).To(HaveKey("operator-1/operator"))
+	})
+
+	It("rejects Since with SinceTime", func() {
+		k := newFakeKubectl(operatorPod("operator-1"))
+
+		_, err := k.GetLogs("default", "app=test", LogOptions{Since: time.Minute, SinceTime: time.Now()})
+		Expect(err).To(MatchError(ContainSubstring("only one of Since and SinceTime")))
+		Expect(logOptions(k)).To(BeEmpty())
+	})
+
+	It("streams the logs during the spec", func() {
+		k := newFakeKubectl(operatorPod("operator-1"))
+
+		buf := gbytes.NewBuffer()
+		Expect(k.StreamLogs("default", "app=test", LogOptions{Container: "operator"}, buf)).To(Succeed())
+		Eventually(buf).Should(gbytes.Say(`\[operator-1/operator\] fake logs`))
+		Expect(logOptions(k)[0].Follow).To(BeTrue())
+	})
+})
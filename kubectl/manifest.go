@@ -0,0 +1,292 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/template"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ObjectRef identifies an object
+type ObjectRef struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+}
+
+// String returns the kind, namespace and name of the object
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s", r.GroupVersionKind.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.GroupVersionKind.Kind, r.Namespace, r.Name)
+}
+
+// refOf returns the reference of the object
+func refOf(obj *unstructured.Unstructured) ObjectRef {
+	return ObjectRef{
+		GroupVersionKind: obj.GroupVersionKind(),
+		Namespace:        obj.GetNamespace(),
+		Name:             obj.GetName(),
+	}
+}
+
+// Manifest is a set of objects to apply together
+type Manifest struct {
+	// Objects are the objects to apply, in dependency order
+	Objects []*unstructured.Unstructured
+	// Created are the objects created by ApplyManifest, objects which
+	// already existed are updated but not recorded
+	Created []ObjectRef
+	// ApplyOptions configures the server-side apply of ApplyManifest,
+	// fields owned by other managers are only taken over if Force is set
+	ApplyOptions ApplyOptions
+}
+
+// manifestFuncs are the functions available in the manifest templates
+var manifestFuncs = template.FuncMap{
+	"env":    os.Getenv,
+	"quote":  strconv.Quote,
+	"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"default": func(def, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// RenderManifest renders the multi-document YAML or JSON data as a Go
+// template with values, e.g. {{ .ClusterName }} or {{ env "HOME" }}, and
+// decodes the objects. A nil values skips templating.
+func RenderManifest(name string, data []byte, values interface{}) (*Manifest, error) {
+	if values != nil {
+		t, err := template.New(name).Funcs(manifestFuncs).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing template %s", name)
+		}
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, values); err != nil {
+			return nil, errors.Wrapf(err, "rendering template %s", name)
+		}
+		data = buf.Bytes()
+	}
+
+	objs, err := decodeObjects(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", name)
+	}
+
+	m := &Manifest{Objects: objs}
+	m.sort()
+	return m, nil
+}
+
+// LoadManifest renders the manifest files of fsys, paths can be files or
+// directories, the YAML and JSON files of directories are read in name
+// order. embed.FS or os.DirFS can be used as fsys.
+func LoadManifest(fsys fs.FS, values interface{}, paths ...string) (*Manifest, error) {
+	m := &Manifest{}
+
+	for _, p := range paths {
+		files, err := manifestFiles(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			data, err := fs.ReadFile(fsys, f)
+			if err != nil {
+				return nil, err
+			}
+
+			rendered, err := RenderManifest(f, data, values)
+			if err != nil {
+				return nil, err
+			}
+			m.Objects = append(m.Objects, rendered.Objects...)
+		}
+	}
+
+	m.sort()
+	return m, nil
+}
+
+// LoadManifestFiles renders the manifest files or directories of the disk
+func LoadManifestFiles(values interface{}, paths ...string) (*Manifest, error) {
+	m := &Manifest{}
+
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+
+		loaded, err := LoadManifest(os.DirFS(filepath.Dir(abs)), values, filepath.Base(abs))
+		if err != nil {
+			return nil, err
+		}
+		m.Objects = append(m.Objects, loaded.Objects...)
+	}
+
+	m.sort()
+	return m, nil
+}
+
+// manifestFiles returns the path, or the manifest files of the directory
+func manifestFiles(fsys fs.FS, p string) ([]string, error) {
+	info, err := fs.Stat(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch path.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, path.Join(p, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// kindOrder returns the apply priority of a kind, lower first
+func kindOrder(gk schema.GroupKind) int {
+	switch {
+	case gk.Group == "apiextensions.k8s.io" && gk.Kind == "CustomResourceDefinition":
+		return 0
+	case gk.Group == "" && gk.Kind == "Namespace":
+		return 1
+	}
+	return 2
+}
+
+// sort puts CRDs then namespaces first, the order of other objects is kept
+func (m *Manifest) sort() {
+	sort.SliceStable(m.Objects, func(i, j int) bool {
+		return kindOrder(m.Objects[i].GroupVersionKind().GroupKind()) < kindOrder(m.Objects[j].GroupVersionKind().GroupKind())
+	})
+}
+
+// ApplyManifest applies the objects of the manifest in order with
+// server-side apply and m.ApplyOptions, objects without namespace are put in
+// namespace. Conflicts with other field managers fail unless Force is set.
+// The created objects are recorded in m.Created, they can be removed with
+// DeleteManifest, e.g. DeferCleanup(k.DeleteManifest, m).
+func (k *Kubectl) ApplyManifest(namespace string, m *Manifest) error {
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range m.Objects {
+		r, err := c.objectResource(namespace, obj)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := c.context()
+		_, err = r.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		cancel()
+		existed := err == nil
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		applied, err := c.apply(namespace, obj, m.ApplyOptions.patchOptions())
+		if err != nil {
+			return errors.Wrapf(err, "applying %s", refOf(obj))
+		}
+		if m.ApplyOptions.DryRun {
+			continue
+		}
+		if !existed {
+			ref := refOf(obj)
+			ref.Namespace = applied.GetNamespace()
+			m.Created = append(m.Created, ref)
+			k.record(ref)
+		}
+
+		// Custom resources can only be applied once their CRD is served
+		if kindOrder(obj.GroupVersionKind().GroupKind()) == 0 {
+			if err := k.WaitForCRDs(obj.GetName()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeleteManifest deletes the objects created by ApplyManifest, in reverse order
+func (k *Kubectl) DeleteManifest(m *Manifest) error {
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.Created) - 1; i >= 0; i-- {
+		if err := c.deleteRef(m.Created[i]); err != nil {
+			return err
+		}
+	}
+	m.Created = nil
+
+	return nil
+}
+
+// deleteRef deletes the object, a missing object is not an error
+func (c *ClientBackend) deleteRef(ref ObjectRef) error {
+	m, err := c.restMapping(ref.GroupVersionKind.GroupKind(), ref.GroupVersionKind.Version)
+	if err != nil {
+		return errors.Wrapf(err, "deleting %s", ref)
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	policy := metav1.DeletePropagationBackground
+	err = c.resourceForMapping(ref.Namespace, m).Delete(ctx, ref.Name, metav1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "deleting %s", ref)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var manifests = fstest.MapFS{
+	"manifests/01-registration.yaml": {Data: []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}-registration
+  namespace: {{ .Namespace }}
+data:
+  cluster: {{ .Name | quote }}
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: {{ .Name }}-secret
+  namespace: {{ .Namespace }}
+data:
+  password: {{ b64enc "secret" }}
+`)},
+	"manifests/00-namespace.yaml": {Data: []byte(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{ .Namespace }}
+`)},
+	"manifests/README.md": {Data: []byte("{{ not a manifest")},
+	"crd.yaml": {Data: []byte(`
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: seedimages.elemental.cattle.io
+`)},
+}
+
+var _ = Describe("manifests", func() {
+	values := map[string]string{"Name": "test", "Namespace": "fleet-default"}
+
+	It("renders templates in dependency order", func() {
+		m, err := LoadManifest(manifests, values, "manifests", "crd.yaml")
+		Expect(err).ToNot(HaveOccurred())
+
+		var kinds []string
+		for _, obj := range m.Objects {
+			kinds = append(kinds, obj.GetKind())
+		}
+		Expect(kinds).To(Equal([]string{"CustomResourceDefinition", "Namespace", "ConfigMap", "Secret"}))
+		Expect(m.Objects[2].GetName()).To(Equal("test-registration"))
+		Expect(m.Objects[2].Object["data"]).To(HaveKeyWithValue("cluster", "test"))
+		Expect(m.Objects[3].Object["data"]).To(HaveKeyWithValue("password", "c2VjcmV0"))
+	})
+
+	It("fails on missing values", func() {
+		_, err := LoadManifest(manifests, map[string]string{"Name": "test"}, "manifests")
+		Expect(err).To(MatchError(ContainSubstring("Namespace")))
+
+		_, err = LoadManifest(manifests, values, "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("loads files from the disk without templating", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "cm.yaml"), []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: raw
+data:
+  value: "{{ .NotRendered }}"
+`), 0644)).To(Succeed())
+
+		m, err := LoadManifestFiles(nil, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.Objects).To(HaveLen(1))
+		Expect(m.Objects[0].Object["data"]).To(HaveKeyWithValue("value", "{{ .NotRendered }}"))
+	})
+
+	It("applies and deletes the created objects", func() {
+		k := newFakeKubectl(&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: "fleet-default"},
+		})
+
+		m, err := LoadManifest(manifests, values, "manifests")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(k.ApplyManifest("default", m)).To(Succeed())
+
+		// The namespace already existed, it is not recorded
+		Expect(m.Created).To(HaveLen(2))
+		Expect(m.Created[0].String()).To(Equal("ConfigMap fleet-default/test-registration"))
+		Expect(k.Exists("fleet-default", "secret", "test-secret")).To(BeTrue())
+
+		Expect(k.DeleteManifest(m)).To(Succeed())
+		Expect(m.Created).To(BeEmpty())
+		Expect(k.Exists("fleet-default", "configmap", "test-registration")).To(BeFalse())
+		Expect(k.Exists("fleet-default", "secret", "test-secret")).To(BeFalse())
+		Expect(k.Exists("", "namespace", "fleet-default")).To(BeTrue())
+	})
+})
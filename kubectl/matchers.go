@@ -0,0 +1,325 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// Object returns a function getting the object, to poll it with Eventually,
+// e.g.
+//
+//	Eventually(k.Object("fleet-default", "machineinventories", "m-1234")).
+//		Should(HaveCondition("Ready", "True"))
+func (k *Kubectl) Object(namespace, resource, name string) func() (*unstructured.Unstructured, error) {
+	return func() (*unstructured.Unstructured, error) {
+		c, err := k.client()
+		if err != nil {
+			return nil, err
+		}
+		return c.get(namespace, resource, name)
+	}
+}
+
+// Getter returns a function getting the typed object, to poll it with
+// Eventually, e.g.
+//
+//	Eventually(Getter[appsv1.Deployment](k, "cattle-system", "rancher")).
+//		Should(HaveReplicas(3))
+func Getter[T any](k *Kubectl, namespace, name string) func() (*T, error) {
+	return func() (*T, error) {
+		return Get[T](k, namespace, name)
+	}
+}
+
+// objectMatcher matches Kubernetes objects, match returns what was observed
+// on the object to explain the failures
+type objectMatcher struct {
+	expected string
+	match    func(obj *unstructured.Unstructured) (bool, string, error)
+}
+
+func (m *objectMatcher) Match(actual interface{}) (bool, error) {
+	obj, err := toUnstructured(actual)
+	if err != nil {
+		return false, err
+	}
+	matched, _, err := m.match(obj)
+	return matched, err
+}
+
+func (m *objectMatcher) FailureMessage(actual interface{}) string {
+	return m.message(actual, "to have")
+}
+
+func (m *objectMatcher) NegatedFailureMessage(actual interface{}) string {
+	return m.message(actual, "not to have")
+}
+
+// message explains the failure with the expected and observed values, and
+// prints the object
+func (m *objectMatcher) message(actual interface{}, expectation string) string {
+	obj, err := toUnstructured(actual)
+	if err != nil {
+		return err.Error()
+	}
+
+	observed := ""
+	if _, o, err := m.match(obj); err != nil {
+		observed = err.Error()
+	} else {
+		observed = o
+	}
+	return fmt.Sprintf("Expected %s %s %s\n    observed: %s\n%s", describeObject(obj), expectation, m.expected, observed, objectYAML(obj))
+}
+
+// objectYAML returns the object as YAML without its managed fields, which
+// are noise in the failures
+func objectYAML(obj *unstructured.Unstructured) string {
+	obj = obj.DeepCopy()
+	obj.SetManagedFields(nil)
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// toUnstructured converts the actual value of the matchers, typed objects
+// are converted with their JSON field names
+func toUnstructured(actual interface{}) (*unstructured.Unstructured, error) {
+	switch obj := actual.(type) {
+	case *unstructured.Unstructured:
+		if obj == nil {
+			return nil, errors.New("expected a Kubernetes object, got nil")
+		}
+		return obj, nil
+	case unstructured.Unstructured:
+		return &obj, nil
+	case nil:
+		return nil, errors.New("expected a Kubernetes object, got nil")
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(actual)
+	if err != nil {
+		return nil, errors.Wrapf(err, "expected a Kubernetes object, got %T", actual)
+	}
+	u := &unstructured.Unstructured{Object: content}
+
+	// Objects built in the specs often miss their TypeMeta
+	if obj, ok := actual.(runtime.Object); ok && u.GetKind() == "" {
+		if gvks, _, err := Scheme.ObjectKinds(obj); err == nil {
+			u.SetGroupVersionKind(gvks[0])
+		}
+	}
+	return u, nil
+}
+
+// describeObject returns the kind, namespace and name of the object
+func describeObject(obj *unstructured.Unstructured) string {
+	name := obj.GetName()
+	if obj.GetNamespace() != "" {
+		name = obj.GetNamespace() + "/" + name
+	}
+	if obj.GetKind() == "" {
+		return name
+	}
+	return obj.GetKind() + " " + name
+}
+
+// toMatcher returns the value if it is a matcher, a matcher of equivalence
+// otherwise, e.g. 3 matches the int64 of unstructured objects. Numbers are
+// compared by value, converting a float64 to an int would truncate it.
+func toMatcher(value interface{}) types.GomegaMatcher {
+	if m, ok := value.(types.GomegaMatcher); ok {
+		return m
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return gomega.BeNumerically("==", value)
+	}
+	return gomega.BeEquivalentTo(value)
+}
+
+// HaveCondition succeeds if the condition of the given type of
+// status.conditions has the status, the type is compared case insensitively
+func HaveCondition(conditionType, status string) types.GomegaMatcher {
+	c := &conditionIs{conditionType: conditionType, status: status}
+	return &objectMatcher{
+		expected: c.String(),
+		match: func(obj *unstructured.Unstructured) (bool, string, error) {
+			matched, err := c.Match(obj)
+			return matched, c.Observe(obj), err
+		},
+	}
+}
+
+// BeReady succeeds for ready objects: pods whose containers are all ready,
+// workloads whose rollout is complete and other objects whose Ready
+// condition is True
+func BeReady() types.GomegaMatcher {
+	return &objectMatcher{
+		expected: "ready",
+		match: func(obj *unstructured.Unstructured) (bool, string, error) {
+			switch obj.GetKind() {
+			case "Pod":
+				pod := &corev1.Pod{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+					return false, "", err
+				}
+				r := EvaluatePod(pod)
+				return r.Ready, r.String(), nil
+			case "Deployment", "StatefulSet", "ReplicaSet", "DaemonSet":
+				done, status, err := rolloutStatus(obj)
+				if err != nil {
+					// A deployment past its progress deadline is not ready
+					return false, err.Error(), nil
+				}
+				return done, status, nil
+			}
+
+			status, found := conditionStatus(obj, "Ready")
+			if !found {
+				return false, "no Ready condition", nil
+			}
+			return strings.EqualFold(status, "True"), "Ready=" + status, nil
+		},
+	}
+}
+
+// HaveLabel succeeds if the object has the label, value is either the
+// expected value or a matcher of the value
+func HaveLabel(key string, value interface{}) types.GomegaMatcher {
+	return haveMetadata("label", key, value, (*unstructured.Unstructured).GetLabels)
+}
+
+// HaveAnnotation succeeds if the object has the annotation, value is either
+// the expected value or a matcher of the value
+func HaveAnnotation(key string, value interface{}) types.GomegaMatcher {
+	return haveMetadata("annotation", key, value, (*unstructured.Unstructured).GetAnnotations)
+}
+
+// haveMetadata matches an entry of the labels or annotations
+func haveMetadata(kind, key string, value interface{}, entries func(*unstructured.Unstructured) map[string]string) types.GomegaMatcher {
+	m := toMatcher(value)
+	return &objectMatcher{
+		expected: fmt.Sprintf("%s %s%s", kind, key, describeValue(value)),
+		match: func(obj *unstructured.Unstructured) (bool, string, error) {
+			v, found := entries(obj)[key]
+			if !found {
+				return false, fmt.Sprintf("no %s %s", kind, key), nil
+			}
+			matched, err := m.Match(v)
+			return matched, fmt.Sprintf("%s %s=%q", kind, key, v), err
+		},
+	}
+}
+
+// HaveJSONPath succeeds if the value of the JSONPath expression matches,
+// value is either the expected value or a matcher. The braces of the
+// expression are optional, an expression returning several results is
+// matched as a slice, e.g.
+//
+//	HaveJSONPath(".status.phase", "Running")
+//	HaveJSONPath(".spec.containers[*].name", ContainElement("operator"))
+func HaveJSONPath(path string, value interface{}) types.GomegaMatcher {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	m := toMatcher(value)
+	return &objectMatcher{
+		expected: path + describeValue(value),
+		match: func(obj *unstructured.Unstructured) (bool, string, error) {
+			v, found, err := jsonPathValue(obj, path)
+			if err != nil || !found {
+				return false, path + " not found", err
+			}
+			matched, err := m.Match(v)
+			if err != nil {
+				return false, "", err
+			}
+			if matched {
+				return true, fmt.Sprintf("%s=%v", path, v), nil
+			}
+			return false, fmt.Sprintf("%s=%v\n%s", path, v, m.FailureMessage(v)), nil
+		},
+	}
+}
+
+// jsonPathValue returns the value of the path, false if it is not found
+func jsonPathValue(obj *unstructured.Unstructured, path string) (interface{}, bool, error) {
+	p := jsonpath.New("match")
+	if err := p.Parse(path); err != nil {
+		return nil, false, errors.Wrapf(err, "invalid JSONPath %s", path)
+	}
+
+	results, err := p.FindResults(obj.Object)
+	if err != nil {
+		// The fields are missing until the controllers fill them
+		return nil, false, nil
+	}
+	var values []interface{}
+	for _, r := range results {
+		for _, v := range r {
+			values = append(values, v.Interface())
+		}
+	}
+	switch len(values) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		return values[0], true, nil
+	}
+	return values, true, nil
+}
+
+// HaveReplicas succeeds for Deployments, StatefulSets and ReplicaSets running
+// exactly n ready replicas, without any surplus replica left by a rollout
+func HaveReplicas(n int) types.GomegaMatcher {
+	return &objectMatcher{
+		expected: fmt.Sprintf("%d replicas", n),
+		match: func(obj *unstructured.Unstructured) (bool, string, error) {
+			switch obj.GetKind() {
+			case "Deployment", "StatefulSet", "ReplicaSet":
+			default:
+				return false, "", errors.Errorf("HaveReplicas expects a Deployment, StatefulSet or ReplicaSet, got %s", obj.GetKind())
+			}
+			replicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+			ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+			return replicas == int64(n) && ready == int64(n), fmt.Sprintf("%d replicas, %d ready", replicas, ready), nil
+		},
+	}
+}
+
+// describeValue describes the expected value or matcher
+func describeValue(value interface{}) string {
+	if m, ok := value.(types.GomegaMatcher); ok {
+		return fmt.Sprintf(" matching %s", strings.TrimPrefix(fmt.Sprintf("%T", m), "*matchers."))
+	}
+	return fmt.Sprintf("=%v", value)
+}
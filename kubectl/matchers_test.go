@@ -0,0 +1,122 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var _ = Describe("matchers", func() {
+	var registration *unstructured.Unstructured
+
+	BeforeEach(func() {
+		registration = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "elemental.cattle.io/v1beta1",
+			"kind":       "MachineRegistration",
+			"metadata": map[string]interface{}{
+				"name":        "test",
+				"namespace":   "fleet-default",
+				"labels":      map[string]interface{}{"app": "elemental"},
+				"annotations": map[string]interface{}{"elemental.cattle.io/hash": "abc123"},
+			},
+			"status": map[string]interface{}{
+				"registrationURL": "https://rancher/elemental/registration/abc",
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "True"},
+				},
+			},
+		}}
+	})
+
+	It("matches conditions and readiness", func() {
+		Expect(registration).To(HaveCondition("ready", "True"))
+		Expect(registration).ToNot(HaveCondition("Ready", "False"))
+		Expect(registration).To(BeReady())
+
+		Expect(readyPod("operator")).To(BeReady())
+		Expect(waitingPod("operator", "ImagePullBackOff", 0)).ToNot(BeReady())
+		Expect(deployment(2, 2)).To(BeReady())
+		Expect(deployment(1, 1)).ToNot(BeReady())
+	})
+
+	It("matches labels, annotations and JSONPath expressions", func() {
+		Expect(registration).To(HaveLabel("app", "elemental"))
+		Expect(registration).To(HaveAnnotation("elemental.cattle.io/hash", HavePrefix("abc")))
+		Expect(registration).ToNot(HaveLabel("missing", ""))
+
+		Expect(registration).To(HaveJSONPath(".status.registrationURL", ContainSubstring("/registration/")))
+		Expect(registration).To(HaveJSONPath("{.status.conditions[*].type}", "Ready"))
+		Expect(registration).ToNot(HaveJSONPath(".status.missing", "value"))
+
+		pod := readyPod("operator")
+		pod.Spec.Containers = []corev1.Container{{Name: "init"}, {Name: "operator"}}
+		Expect(pod).To(HaveJSONPath(".spec.containers[*].name", ContainElement("operator")))
+	})
+
+	It("matches replicas", func() {
+		d := deployment(2, 2)
+		Expect(d).ToNot(HaveReplicas(2))
+		d.Status.ReadyReplicas = 2
+		Expect(d).To(HaveReplicas(2))
+		Expect(d).ToNot(HaveReplicas(3))
+		Expect(d).To(HaveJSONPath(".status.readyReplicas", 2))
+		Expect(d).ToNot(HaveJSONPath(".status.readyReplicas", 2.5))
+		_, err := HaveReplicas(1).Match(readyPod("operator"))
+		Expect(err).To(MatchError(ContainSubstring("HaveReplicas expects a Deployment")))
+	})
+
+	It("prints the expected and observed values on failure", func() {
+		registration.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "elemental-operator", Operation: metav1.ManagedFieldsOperationApply}})
+		matcher := HaveLabel("app", "rancher")
+		Expect(matcher.Match(registration)).To(BeFalse())
+		Expect(matcher.FailureMessage(registration)).To(And(
+			HavePrefix("Expected MachineRegistration fleet-default/test to have label app=rancher\n    observed: label app=\"elemental\"\n"),
+			ContainSubstring("registrationURL: https://rancher/elemental/registration/abc"),
+			Not(ContainSubstring("managedFields")),
+		))
+
+		matcher = HaveJSONPath(".status.registrationURL", "https://rancher/elemental/registration/xyz")
+		Expect(matcher.Match(registration)).To(BeFalse())
+		Expect(matcher.FailureMessage(registration)).To(And(
+			ContainSubstring("observed: {.status.registrationURL}=https://rancher/elemental/registration/abc"),
+			ContainSubstring("to be equivalent to"),
+			ContainSubstring("registration/xyz"),
+			ContainSubstring("apiVersion: elemental.cattle.io/v1beta1"),
+		))
+
+		typed := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "rancher"}}
+		Expect(HaveReplicas(1).FailureMessage(typed)).To(HavePrefix("Expected Deployment rancher to have 1 replicas\n    observed: 0 replicas, 0 ready\n"))
+
+		_, err := BeReady().Match(nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("polls objects with the getters", func() {
+		d := deployment(2, 2)
+		d.Status.ReadyReplicas = 2
+		k := newFakeKubectl(readyPod("operator"), d)
+
+		Eventually(k.Object("default", "pods", "operator")).Should(And(BeReady(), HaveLabel("app", "test")))
+		Eventually(Getter[appsv1.Deployment](k, "cattle-elemental-system", "elemental-operator")).Should(HaveReplicas(2))
+		Eventually(Getter[corev1.Pod](k, "default", "operator")).Should(HaveJSONPath(".status.phase", "Running"))
+	})
+})
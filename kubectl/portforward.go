@@ -0,0 +1,385 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	wait "github.com/rancher-sandbox/ele-testhelpers/helpers"
+)
+
+// DialerFunc creates the dialer of a port-forward, url is the portforward
+// subresource of the pod
+type DialerFunc func(u *url.URL) (httpstream.Dialer, error)
+
+// PortForward is a running port-forward to a pod
+type PortForward struct {
+	// LocalPort is the port listening on 127.0.0.1
+	LocalPort int
+
+	k         *Kubectl
+	namespace string
+	target    string
+	port      int
+	done      chan struct{}
+	cancel    context.CancelFunc
+	errOut    io.Writer
+}
+
+// PortForward forwards a free local port to the port of the target, which
+// is a pod ("name" or "pod/name") or a service ("svc/name" or
+// "service/name"). For services port is the service port, it is forwarded
+// to the target port of a ready pod of the service.
+//
+// PortForward waits up to PollTimeout for a running pod, then returns once
+// the tunnel is ready. The tunnel is restarted if
+// the pod is replaced, e.g. after a rollout, and stopped when the context is
+// done or with Stop.
+func (k *Kubectl) PortForward(ctx context.Context, namespace, target string, port int) (*PortForward, error) {
+	localPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pf := &PortForward{
+		LocalPort: localPort,
+		k:         k,
+		namespace: namespace,
+		target:    target,
+		port:      port,
+		done:      make(chan struct{}),
+		cancel:    cancel,
+		errOut:    ginkgo.GinkgoWriter,
+	}
+
+	if err := pf.waitForPod(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	ready := make(chan error, 1)
+	go pf.run(ctx, ready)
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			cancel()
+			<-pf.done
+			return nil, err
+		}
+	case <-time.After(k.PollTimeout):
+		cancel()
+		<-pf.done
+		return nil, errors.Errorf("timed out after %s waiting for the port-forward to %s", k.PollTimeout, target)
+	}
+
+	return pf, nil
+}
+
+// Address returns the local address of the tunnel
+func (pf *PortForward) Address() string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(pf.LocalPort))
+}
+
+// Stop stops the tunnel and waits for it to be closed
+func (pf *PortForward) Stop() {
+	pf.cancel()
+	<-pf.done
+}
+
+// Done is closed once the tunnel is stopped
+func (pf *PortForward) Done() <-chan struct{} {
+	return pf.done
+}
+
+// run forwards the port until the context is done, the first outcome is
+// sent to ready, the tunnel is then restarted on failure
+func (pf *PortForward) run(ctx context.Context, ready chan<- error) {
+	defer close(pf.done)
+
+	first := true
+	for {
+		err := pf.forward(ctx, func() {
+			if first {
+				first = false
+				ready <- nil
+			}
+		})
+		if first {
+			if err == nil {
+				// The tunnel was closed before being ready
+				err = ctx.Err()
+			}
+			if err == nil {
+				err = errors.Errorf("port-forward to %s closed before being ready", pf.target)
+			}
+			ready <- err
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(pf.errOut, "port-forward to %s failed, restarting: %s\n", pf.target, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pf.k.PollInterval):
+		}
+	}
+}
+
+// waitForPod waits for the target to have a running pod, errors other than
+// a missing or not running pod are returned right away
+func (pf *PortForward) waitForPod(ctx context.Context) error {
+	var last error
+	err := wait.PollImmediateWithContext(ctx, pf.k.PollInterval, pf.k.PollTimeout, func(context.Context) (bool, error) {
+		_, _, err := pf.resolve()
+		var notRunning *podNotRunningError
+		if apierrors.IsNotFound(err) || errors.As(err, &notRunning) {
+			last = err
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == wait.ErrWaitTimeout {
+		return errors.Wrapf(last, "timed out after %s waiting for a running pod of %s", pf.k.PollTimeout, pf.target)
+	}
+	return err
+}
+
+// podNotRunningError is returned by resolve while the target has no running
+// pod
+type podNotRunningError struct {
+	msg string
+}
+
+func (e *podNotRunningError) Error() string {
+	return e.msg
+}
+
+// forward runs a tunnel to the current pod of the target until the context
+// is done, the connection is lost or the pod is replaced
+func (pf *PortForward) forward(ctx context.Context, onReady func()) error {
+	c, err := pf.k.client()
+	if err != nil {
+		return err
+	}
+
+	pod, remotePort, err := pf.resolve()
+	if err != nil {
+		return err
+	}
+
+	u, err := c.podURL(pod.Namespace, pod.Name, "portforward")
+	if err != nil {
+		return err
+	}
+	dialer, err := c.dialer(u)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	readyCh := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"},
+		[]string{fmt.Sprintf("%d:%d", pf.LocalPort, remotePort)}, stop, readyCh, io.Discard, pf.errOut)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- fw.ForwardPorts() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		close(stop)
+		<-errCh
+		return ctx.Err()
+	case <-readyCh:
+		onReady()
+	}
+
+	ticker := time.NewTicker(pf.k.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			close(stop)
+			<-errCh
+			return nil
+		case <-ticker.C:
+			if pf.replaced(pod) {
+				close(stop)
+				<-errCh
+				return nil
+			}
+		}
+	}
+}
+
+// replaced returns true if the pod is gone, restarted or not running
+func (pf *PortForward) replaced(pod *corev1.Pod) bool {
+	current, err := Get[corev1.Pod](pf.k, pod.Namespace, pod.Name)
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	return current.UID != pod.UID || current.DeletionTimestamp != nil || current.Status.Phase != corev1.PodRunning
+}
+
+// resolve returns the pod and port to forward to
+func (pf *PortForward) resolve() (*corev1.Pod, int, error) {
+	kind, name, found := strings.Cut(pf.target, "/")
+	if !found {
+		kind, name = "pod", pf.target
+	}
+
+	switch kind {
+	case "pod", "pods", "po":
+		pod, err := Get[corev1.Pod](pf.k, pf.namespace, name)
+		if err != nil {
+			return nil, 0, err
+		}
+		if pod.Status.Phase != corev1.PodRunning {
+			return nil, 0, &podNotRunningError{msg: fmt.Sprintf("pod %s is %s", name, pod.Status.Phase)}
+		}
+		return pod, pf.port, nil
+	case "service", "services", "svc":
+		return pf.resolveService(name)
+	}
+	return nil, 0, errors.Errorf("cannot port-forward to %s, only pods and services are supported", pf.target)
+}
+
+// resolveService returns a ready pod of the service and its target port
+func (pf *PortForward) resolveService(name string) (*corev1.Pod, int, error) {
+	svc, err := Get[corev1.Service](pf.k, pf.namespace, name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var servicePort *corev1.ServicePort
+	for i := range svc.Spec.Ports {
+		if int(svc.Spec.Ports[i].Port) == pf.port {
+			servicePort = &svc.Spec.Ports[i]
+		}
+	}
+	if servicePort == nil {
+		return nil, 0, errors.Errorf("service %s has no port %d", name, pf.port)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil, 0, errors.Errorf("service %s has no selector", name)
+	}
+
+	pods, err := List[corev1.Pod](pf.k, pf.namespace, ListOptions{LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String()})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil || !podConditionTrue(pod, corev1.PodReady) {
+			continue
+		}
+		port, err := targetPort(pod, servicePort)
+		if err != nil {
+			return nil, 0, err
+		}
+		return pod, port, nil
+	}
+	return nil, 0, &podNotRunningError{msg: fmt.Sprintf("service %s has no ready pod", name)}
+}
+
+// targetPort returns the container port of the pod matching the service port
+func targetPort(pod *corev1.Pod, servicePort *corev1.ServicePort) (int, error) {
+	switch {
+	case servicePort.TargetPort.Type == intstr.String && servicePort.TargetPort.StrVal != "":
+		for _, c := range pod.Spec.Containers {
+			for _, p := range c.Ports {
+				if p.Name == servicePort.TargetPort.StrVal {
+					return int(p.ContainerPort), nil
+				}
+			}
+		}
+		return 0, errors.Errorf("pod %s has no port named %s", pod.Name, servicePort.TargetPort.StrVal)
+	case servicePort.TargetPort.IntValue() != 0:
+		return servicePort.TargetPort.IntValue(), nil
+	}
+	return int(servicePort.Port), nil
+}
+
+// podConditionTrue returns true if the condition of the pod is true
+func podConditionTrue(pod *corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == conditionType {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// dialer returns the SPDY dialer of the URL
+func (c *ClientBackend) dialer(u *url.URL) (httpstream.Dialer, error) {
+	if c.NewDialer != nil {
+		return c.NewDialer(u)
+	}
+	if c.Config == nil {
+		return nil, errors.New("port-forwarding needs a REST config")
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	return spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, u), nil
+}
+
+// freePort returns a free local TCP port
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
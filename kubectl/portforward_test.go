@@ -0,0 +1,189 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/portforward"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// fakeConnection is a stream connection without streams
+type fakeConnection struct {
+	once   sync.Once
+	closed chan bool
+}
+
+func (c *fakeConnection) CreateStream(http.Header) (httpstream.Stream, error) {
+	return nil, errors.New("no stream")
+}
+
+func (c *fakeConnection) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConnection) CloseChan() <-chan bool             { return c.closed }
+func (c *fakeConnection) SetIdleTimeout(time.Duration)       {}
+func (c *fakeConnection) RemoveStreams(...httpstream.Stream) {}
+
+// fakeDialer records the dialed URLs
+type fakeDialer struct {
+	mu   sync.Mutex
+	urls []string
+	err  error
+}
+
+func (d *fakeDialer) dialer(u *url.URL) (httpstream.Dialer, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.urls = append(d.urls, u.Path)
+	return d, nil
+}
+
+func (d *fakeDialer) Dial(...string) (httpstream.Connection, string, error) {
+	if d.err != nil {
+		return nil, "", d.err
+	}
+	return &fakeConnection{closed: make(chan bool)}, portforward.PortForwardProtocolV1Name, nil
+}
+
+func (d *fakeDialer) dialed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.urls...)
+}
+
+func runningPod(name, uid string) *corev1.Pod {
+	pod := readyPod(name)
+	pod.UID = types.UID(uid)
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	pod.Spec.Containers = []corev1.Container{{
+		Name:  "operator",
+		Ports: []corev1.ContainerPort{{Name: "https", ContainerPort: 8443}},
+	}}
+	return pod
+}
+
+var _ = Describe("port-forward", func() {
+	var (
+		k      *Kubectl
+		dialer *fakeDialer
+	)
+
+	BeforeEach(func() {
+		svc := &corev1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: metav1.ObjectMeta{Name: "elemental-operator", Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				Selector: map[string]string{"app": "test"},
+				Ports:    []corev1.ServicePort{{Port: 443, TargetPort: intstr.FromString("https")}},
+			},
+		}
+
+		k = newFakeKubectl(svc, runningPod("operator-1", "1"))
+		dialer = &fakeDialer{}
+		k.Backend.(*ClientBackend).NewDialer = dialer.dialer
+	})
+
+	It("forwards a local port to the pod of a service", func() {
+		pf, err := k.PortForward(context.Background(), "default", "svc/elemental-operator", 443)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(pf.Stop)
+
+		Expect(pf.LocalPort).ToNot(BeZero())
+		conn, err := net.Dial("tcp", pf.Address())
+		Expect(err).ToNot(HaveOccurred())
+		conn.Close()
+
+		Expect(dialer.dialed()).To(Equal([]string{"/api/v1/namespaces/default/pods/operator-1/portforward"}))
+	})
+
+	It("restarts when the pod is replaced", func() {
+		pf, err := k.PortForward(context.Background(), "default", "operator-1", 8443)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(pf.Stop)
+
+		Expect(k.DeleteResource("default", "pod", "operator-1")).To(Succeed())
+		Expect(k.ServerSideApply("default", runningPod("operator-1", "2"), ApplyOptions{})).ToNot(BeNil())
+
+		Eventually(dialer.dialed).Should(HaveLen(2))
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", pf.Address())
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}).Should(Succeed())
+	})
+
+	It("waits for a running pod", func() {
+		pending := runningPod("operator-2", "3")
+		pending.Status.Phase = corev1.PodPending
+		Expect(k.ServerSideApply("default", pending, ApplyOptions{})).ToNot(BeNil())
+		updatePodLater(k, runningPod("operator-2", "3"))
+
+		pf, err := k.PortForward(context.Background(), "default", "pod/operator-2", 8443)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(pf.Stop)
+		Expect(dialer.dialed()).To(Equal([]string{"/api/v1/namespaces/default/pods/operator-2/portforward"}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = k.PortForward(ctx, "default", "pod/missing", 8443)
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("stops with the context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		pf, err := k.PortForward(ctx, "default", "pod/operator-1", 8443)
+		Expect(err).ToNot(HaveOccurred())
+
+		cancel()
+		Eventually(pf.Done()).Should(BeClosed())
+		_, err = net.Dial("tcp", pf.Address())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reports tunnels which cannot be opened", func() {
+		dialer.err = errors.New("upgrade refused")
+
+		_, err := k.PortForward(context.Background(), "default", "svc/elemental-operator", 443)
+		Expect(err).To(MatchError(ContainSubstring("upgrade refused")))
+
+		_, err = k.PortForward(context.Background(), "default", "svc/elemental-operator", 80)
+		Expect(err).To(MatchError(ContainSubstring("no port 80")))
+
+		_, err = k.PortForward(context.Background(), "default", "deployment/elemental-operator", 443)
+		Expect(err).To(MatchError(ContainSubstring("only pods and services")))
+	})
+})
@@ -0,0 +1,273 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// UnrecoverableReasons are the container waiting reasons which fail the
+// readiness waits immediately
+var UnrecoverableReasons = map[string]bool{
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// ImagePullBackOffTimeout is how long the containers of a pod can fail to
+// pull their image before the pod is considered unrecoverable, registries
+// can be briefly unavailable or the image pushed late
+var ImagePullBackOffTimeout = 3 * time.Minute
+
+// CrashLoopRestartLimit is the number of restarts after which a container
+// in CrashLoopBackOff is considered unrecoverable, operators often restart
+// a few times while their dependencies start
+var CrashLoopRestartLimit int32 = 5
+
+// PodReadiness explains the readiness of a pod
+type PodReadiness struct {
+	Name  string
+	Phase corev1.PodPhase
+	// Ready is true if all the containers are ready and running
+	Ready bool
+	// Completed is true for succeeded pods, they are ignored by the report
+	Completed bool
+	// Replaced is true for failed pods which are replaced by their
+	// controller or were evicted, they are ignored by the report
+	Replaced bool
+	// Unrecoverable is true if the pod cannot become ready without an
+	// intervention, e.g. a failed pod or an image which cannot be pulled
+	Unrecoverable bool
+	// Restarts is the sum of the restarts of the containers
+	Restarts int32
+	// Reasons explains why the pod is not ready
+	Reasons []string
+}
+
+// String summarizes the readiness of the pod
+func (p PodReadiness) String() string {
+	state := "not ready"
+	switch {
+	case p.Completed:
+		state = "completed"
+	case p.Replaced:
+		state = "replaced"
+	case p.Ready:
+		state = "ready"
+	case p.Unrecoverable:
+		state = "unrecoverable"
+	}
+
+	s := fmt.Sprintf("%s %s (phase=%s restarts=%d)", p.Name, state, p.Phase, p.Restarts)
+	if len(p.Reasons) > 0 {
+		s += ": " + strings.Join(p.Reasons, ", ")
+	}
+	return s
+}
+
+// ReadinessReport is the readiness of a set of pods
+type ReadinessReport struct {
+	Pods []PodReadiness
+}
+
+// Ready returns true if at least one pod is running and all the running
+// pods are ready, completed and replaced pods are ignored
+func (r *ReadinessReport) Ready() bool {
+	running := 0
+	for _, p := range r.Pods {
+		if p.Completed || p.Replaced {
+			continue
+		}
+		if !p.Ready {
+			return false
+		}
+		running++
+	}
+	return running > 0
+}
+
+// Err returns an error describing the first unrecoverable pod, if any
+func (r *ReadinessReport) Err() error {
+	for _, p := range r.Pods {
+		if p.Unrecoverable {
+			return errors.Errorf("pod %s", p)
+		}
+	}
+	return nil
+}
+
+// String summarizes the readiness of all the pods
+func (r *ReadinessReport) String() string {
+	if len(r.Pods) == 0 {
+		return "no pod found"
+	}
+
+	var pods []string
+	for _, p := range r.Pods {
+		pods = append(pods, p.String())
+	}
+	return strings.Join(pods, "; ")
+}
+
+// EvaluatePod explains the readiness of the pod
+func EvaluatePod(pod *corev1.Pod) PodReadiness {
+	r := PodReadiness{Name: pod.Name, Phase: pod.Status.Phase}
+
+	for _, s := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		r.Restarts += s.RestartCount
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		r.Completed = true
+		return r
+	case corev1.PodFailed:
+		// Evicted pods and the pods of controllers are replaced
+		if pod.Status.Reason == "Evicted" || len(pod.OwnerReferences) > 0 {
+			r.Replaced = true
+		} else {
+			r.Unrecoverable = true
+		}
+		r.Reasons = append(r.Reasons, strings.TrimSpace(fmt.Sprintf("failed %s %s", pod.Status.Reason, pod.Status.Message)))
+		return r
+	}
+
+	if pod.DeletionTimestamp != nil {
+		r.Reasons = append(r.Reasons, "terminating")
+	}
+
+	for _, c := range pod.Status.Conditions {
+		if c.Status != corev1.ConditionTrue && c.Reason != "" {
+			r.Reasons = append(r.Reasons, strings.TrimSpace(fmt.Sprintf("condition %s=%s %s %s", c.Type, c.Status, c.Reason, c.Message)))
+		}
+	}
+
+	for _, s := range pod.Status.InitContainerStatuses {
+		if s.State.Terminated != nil && s.State.Terminated.ExitCode == 0 {
+			continue
+		}
+		r.Reasons = append(r.Reasons, "init "+r.containerReason(pod, s))
+	}
+
+	ready := len(pod.Status.ContainerStatuses) > 0 && len(pod.Status.ContainerStatuses) >= len(pod.Spec.Containers)
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.Ready && s.State.Running != nil {
+			continue
+		}
+		ready = false
+		r.Reasons = append(r.Reasons, r.containerReason(pod, s))
+	}
+	if len(pod.Status.ContainerStatuses) == 0 && len(r.Reasons) == 0 {
+		r.Reasons = append(r.Reasons, "no container status")
+	}
+
+	r.Ready = ready && pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning
+	return r
+}
+
+// containerReason explains why the container is not ready and flags the
+// unrecoverable states
+func (r *PodReadiness) containerReason(pod *corev1.Pod, s corev1.ContainerStatus) string {
+	state := s.State
+	switch {
+	case state.Waiting != nil:
+		reason := state.Waiting.Reason
+		switch {
+		case UnrecoverableReasons[reason]:
+			r.Unrecoverable = true
+		case reason == "CrashLoopBackOff" && s.RestartCount >= CrashLoopRestartLimit:
+			r.Unrecoverable = true
+		case (reason == "ImagePullBackOff" || reason == "ErrImagePull") && notReadySince(pod) > ImagePullBackOffTimeout:
+			r.Unrecoverable = true
+		}
+		return strings.TrimSpace(fmt.Sprintf("container %s waiting: %s %s", s.Name, reason, state.Waiting.Message))
+	case state.Terminated != nil:
+		return strings.TrimSpace(fmt.Sprintf("container %s terminated: %s exit code %d %s",
+			s.Name, state.Terminated.Reason, state.Terminated.ExitCode, state.Terminated.Message))
+	case state.Running != nil:
+		return fmt.Sprintf("container %s running but not ready", s.Name)
+	}
+	return fmt.Sprintf("container %s not started", s.Name)
+}
+
+// notReadySince returns how long the containers of the pod have not been
+// ready, zero if unknown
+func notReadySince(pod *corev1.Pod) time.Duration {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.ContainersReady && c.Status != corev1.ConditionTrue && !c.LastTransitionTime.IsZero() {
+			return time.Since(c.LastTransitionTime.Time)
+		}
+	}
+	if pod.Status.StartTime != nil {
+		return time.Since(pod.Status.StartTime.Time)
+	}
+	return 0
+}
+
+// EvaluatePods explains the readiness of the pods
+func EvaluatePods(pods []corev1.Pod) *ReadinessReport {
+	report := &ReadinessReport{}
+	for i := range pods {
+		report.Pods = append(report.Pods, EvaluatePod(&pods[i]))
+	}
+	return report
+}
+
+// PodReadiness evaluates the readiness of the pods matching the selector
+func (k *Kubectl) PodReadiness(namespace, selector string) (*ReadinessReport, error) {
+	pods, err := List[corev1.Pod](k, namespace, ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return EvaluatePods(pods), nil
+}
+
+// podObjectsReport evaluates the readiness of the watched pods
+func podObjectsReport(objs []*unstructured.Unstructured) (*ReadinessReport, error) {
+	pods := make([]corev1.Pod, len(objs))
+	for i, obj := range objs {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pods[i]); err != nil {
+			return nil, err
+		}
+	}
+	return EvaluatePods(pods), nil
+}
+
+// waitForPodsReady watches the pods until they are ready, it fails as soon
+// as a pod is unrecoverable and reports the readiness of the pods on timeout
+func (k *Kubectl) waitForPodsReady(namespace, selector string) error {
+	return k.watchFor(namespace, "pods", WatchOptions{LabelSelector: selector}, func(objs []*unstructured.Unstructured) (bool, error) {
+		report, err := podObjectsReport(objs)
+		if err != nil {
+			return false, err
+		}
+		if err := report.Err(); err != nil {
+			return false, err
+		}
+		return report.Ready(), nil
+	}, func(objs []*unstructured.Unstructured) string {
+		report, err := podObjectsReport(objs)
+		if err != nil {
+			return err.Error()
+		}
+		return report.String()
+	})
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// waitingPod returns a pod whose container is waiting for the reason
+func waitingPod(name, reason string, restarts int32) *corev1.Pod {
+	pod := readyPod(name)
+	pod.Spec.Containers = []corev1.Container{{Name: "test"}}
+	pod.Status.ContainerStatuses[0].Ready = false
+	pod.Status.ContainerStatuses[0].RestartCount = restarts
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: "back-off pulling image"},
+	}
+	return pod
+}
+
+var _ = Describe("pod readiness", func() {
+	It("explains why pods are not ready", func() {
+		initializing := readyPod("init")
+		initializing.Status.Phase = corev1.PodPending
+		initializing.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "setup",
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		}}
+		unschedulable := readyPod("unschedulable")
+		unschedulable.Status.Phase = corev1.PodPending
+		unschedulable.Status.ContainerStatuses = nil
+		unschedulable.Status.Conditions = []corev1.PodCondition{{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Unschedulable",
+			Message: "0/1 nodes are available",
+		}}
+
+		report := EvaluatePods([]corev1.Pod{
+			*readyPod("ready"),
+			*initializing,
+			*unschedulable,
+			*waitingPod("crashing", "CrashLoopBackOff", 1),
+		})
+		Expect(report.Ready()).To(BeFalse())
+		Expect(report.Err()).ToNot(HaveOccurred())
+
+		Expect(report.Pods[0].Ready).To(BeTrue())
+		Expect(report.Pods[1].Reasons).To(ContainElement("init container setup running but not ready"))
+		Expect(report.Pods[2].String()).To(Equal("unschedulable not ready (phase=Pending restarts=0): condition PodScheduled=False Unschedulable 0/1 nodes are available"))
+		Expect(report.Pods[3].Restarts).To(Equal(int32(1)))
+		Expect(report.Pods[3].Reasons).To(ConsistOf("container test waiting: CrashLoopBackOff back-off pulling image"))
+	})
+
+	It("flags unrecoverable pods", func() {
+		failed := readyPod("failed")
+		failed.Status.Phase = corev1.PodFailed
+		failed.Status.Reason = "Error"
+
+		pulling := waitingPod("image", "ImagePullBackOff", 0)
+		pulling.Status.Conditions = []corev1.PodCondition{{
+			Type:               corev1.ContainersReady,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+		}}
+		Expect(EvaluatePod(pulling).Unrecoverable).To(BeFalse())
+		pulling.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-ImagePullBackOffTimeout - time.Minute))
+		Expect(EvaluatePod(pulling).Unrecoverable).To(BeTrue())
+
+		Expect(EvaluatePod(failed).Unrecoverable).To(BeTrue())
+		Expect(EvaluatePod(waitingPod("image", "InvalidImageName", 0)).Unrecoverable).To(BeTrue())
+		Expect(EvaluatePod(waitingPod("crashing", "CrashLoopBackOff", 1)).Unrecoverable).To(BeFalse())
+		Expect(EvaluatePod(waitingPod("crashing", "CrashLoopBackOff", CrashLoopRestartLimit)).Unrecoverable).To(BeTrue())
+	})
+
+	It("ignores completed pods but needs a running pod", func() {
+		completed := readyPod("job")
+		completed.Status.Phase = corev1.PodSucceeded
+
+		Expect(EvaluatePods(nil).Ready()).To(BeFalse())
+		Expect(EvaluatePods([]corev1.Pod{*completed}).Ready()).To(BeFalse())
+		Expect(EvaluatePods([]corev1.Pod{*completed, *readyPod("operator")}).Ready()).To(BeTrue())
+	})
+
+	It("ignores the evicted pods and the failed pods of controllers", func() {
+		evicted := readyPod("evicted")
+		evicted.Status.Phase = corev1.PodFailed
+		evicted.Status.Reason = "Evicted"
+		owned := readyPod("owned")
+		owned.Status.Phase = corev1.PodFailed
+		owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "operator-5d8f", UID: "1"}}
+
+		report := EvaluatePods([]corev1.Pod{*evicted, *owned, *readyPod("operator")})
+		Expect(report.Err()).ToNot(HaveOccurred())
+		Expect(report.Ready()).To(BeTrue())
+		Expect(report.String()).To(ContainSubstring("evicted replaced"))
+	})
+
+	It("is used by the readiness helpers", func() {
+		k := newFakeKubectl()
+		k.PollTimeout = 200 * time.Millisecond
+
+		Expect(k.NamespaceWithReadyPod("default", "app=test")).To(BeFalse())
+		err := k.WaitForNamespaceWithPod("default", "app=test")
+		Expect(err).To(MatchError(And(ContainSubstring("timed out"), ContainSubstring("no pod found"))))
+
+		k = newFakeKubectl(readyPod("ready"), waitingPod("image", "InvalidImageName", 0))
+		k.PollTimeout = 2 * time.Second
+
+		Expect(k.NamespaceWithReadyPod("default", "app=test")).To(BeFalse())
+		start := time.Now()
+		err = k.WaitForNamespaceWithPod("default", "app=test")
+		Expect(err).To(MatchError(ContainSubstring("pod image unrecoverable (phase=Running restarts=0): container test waiting: InvalidImageName")))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+})
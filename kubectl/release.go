@@ -0,0 +1,252 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/onsi/gomega/types"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+// ReleaseRevision is a revision of the history of a release
+type ReleaseRevision struct {
+	Revision int
+	Updated  time.Time
+	Status   string
+	// Chart is the name and the version of the chart, e.g. rancher-2.9.0
+	Chart       string
+	AppVersion  string
+	Description string
+}
+
+// Get returns the release with its computed values
+func (h *Helm) Get(ctx context.Context, namespace, release string) (*Release, error) {
+	r, err := h.Status(ctx, namespace, release)
+	if err != nil {
+		return nil, err
+	}
+
+	r.ComputedValues, err = h.GetValues(ctx, namespace, release, true)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// History returns the revisions of the release, oldest first
+func (h *Helm) History(_ context.Context, namespace, release string) ([]ReleaseRevision, error) {
+	cfg, err := h.configuration(h.namespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := action.NewHistory(cfg).Run(release)
+	if err != nil {
+		return nil, errors.Wrapf(err, "helm history of %s", release)
+	}
+	releaseutil.SortByRevision(releases)
+
+	revisions := make([]ReleaseRevision, 0, len(releases))
+	for _, item := range releases {
+		r := releaseOf(item)
+		revisions = append(revisions, ReleaseRevision{
+			Revision:    r.Revision,
+			Updated:     r.Updated,
+			Status:      r.Status,
+			Chart:       r.Chart + "-" + r.ChartVersion,
+			AppVersion:  r.AppVersion,
+			Description: r.Description,
+		})
+	}
+	return revisions, nil
+}
+
+// Release returns a function getting the release with its computed values,
+// to poll it with Eventually, e.g.
+//
+//	Eventually(k.Helm().Release("cattle-elemental-system", "elemental-operator")).
+//		Should(And(BeDeployedRelease(), HaveChartVersion("1.7.0")))
+func (h *Helm) Release(namespace, release string) func() (*Release, error) {
+	return func() (*Release, error) {
+		ctx := context.Background()
+		if h.k.PollTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.k.PollTimeout)
+			defer cancel()
+		}
+		return h.Get(ctx, namespace, release)
+	}
+}
+
+// releaseMatcher matches Helm releases, match returns what was observed on
+// the release to explain the failures
+type releaseMatcher struct {
+	expected string
+	match    func(r *Release) (bool, string, error)
+}
+
+func (m *releaseMatcher) Match(actual interface{}) (bool, error) {
+	r, err := toRelease(actual)
+	if err != nil {
+		return false, err
+	}
+	matched, _, err := m.match(r)
+	return matched, err
+}
+
+func (m *releaseMatcher) FailureMessage(actual interface{}) string {
+	return m.message(actual, "to have")
+}
+
+func (m *releaseMatcher) NegatedFailureMessage(actual interface{}) string {
+	return m.message(actual, "not to have")
+}
+
+// message explains the failure and prints the release
+func (m *releaseMatcher) message(actual interface{}, expectation string) string {
+	r, err := toRelease(actual)
+	if err != nil {
+		return err.Error()
+	}
+
+	observed := ""
+	if _, o, err := m.match(r); err != nil {
+		observed = err.Error()
+	} else {
+		observed = o
+	}
+	return fmt.Sprintf("Expected release %s %s %s, observed %s\n%s", r.Name, expectation, m.expected, observed, describeRelease(r))
+}
+
+// toRelease returns the release of the actual value
+func toRelease(actual interface{}) (*Release, error) {
+	switch r := actual.(type) {
+	case *Release:
+		if r != nil {
+			return r, nil
+		}
+	case Release:
+		return &r, nil
+	case Values:
+		// HaveReleaseValue also matches values returned by Helm.GetValues
+		return &Release{ComputedValues: r}, nil
+	}
+	return nil, errors.Errorf("expected a Helm release, got %T", actual)
+}
+
+// describeRelease summarizes the release and its values
+func describeRelease(r *Release) string {
+	values := r.ComputedValues
+	if values == nil {
+		values = r.Values
+	}
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		data = []byte(err.Error())
+	}
+	return fmt.Sprintf("namespace: %s\nrevision: %d\nstatus: %s\nchart: %s-%s\nappVersion: %s\ndescription: %s\nvalues:\n%s",
+		r.Namespace, r.Revision, r.Status, r.Chart, r.ChartVersion, r.AppVersion, r.Description, data)
+}
+
+// BeDeployedRelease succeeds if the status of the release is deployed
+func BeDeployedRelease() types.GomegaMatcher {
+	return &releaseMatcher{
+		expected: "status deployed",
+		match: func(r *Release) (bool, string, error) {
+			return r.Status == "deployed", "status " + r.Status, nil
+		},
+	}
+}
+
+// HaveChartVersion succeeds if the version of the chart of the release
+// matches, version is either the expected version or a matcher
+func HaveChartVersion(version interface{}) types.GomegaMatcher {
+	m := toMatcher(version)
+	return &releaseMatcher{
+		expected: "chart version" + describeValue(version),
+		match: func(r *Release) (bool, string, error) {
+			matched, err := m.Match(r.ChartVersion)
+			return matched, "chart version " + r.ChartVersion, err
+		},
+	}
+}
+
+// HaveAppVersion succeeds if the application version of the chart of the
+// release matches, version is either the expected version or a matcher
+func HaveAppVersion(version interface{}) types.GomegaMatcher {
+	m := toMatcher(version)
+	return &releaseMatcher{
+		expected: "app version" + describeValue(version),
+		match: func(r *Release) (bool, string, error) {
+			matched, err := m.Match(r.AppVersion)
+			return matched, "app version " + r.AppVersion, err
+		},
+	}
+}
+
+// HaveReleaseValue succeeds if the value of the dotted path (e.g.
+// "image.tag") matches, value is either the expected value or a matcher.
+// The computed values are used if they are known, see Helm.Get, the supplied
+// values otherwise. It is not named HaveValue to not clash with the Gomega
+// matcher when both packages are dot imported.
+func HaveReleaseValue(path string, value interface{}) types.GomegaMatcher {
+	m := toMatcher(value)
+	return &releaseMatcher{
+		expected: "value " + path + describeValue(value),
+		match: func(r *Release) (bool, string, error) {
+			values := r.ComputedValues
+			if values == nil {
+				values = r.Values
+			}
+			v, found := valueAt(values, path)
+			if !found {
+				return false, path + " not found", nil
+			}
+			matched, err := m.Match(v)
+			return matched, fmt.Sprintf("%s=%v", path, v), err
+		},
+	}
+}
+
+// valueAt returns the value of the dotted path
+func valueAt(values Values, path string) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(values)
+	for _, key := range strings.Split(path, ".") {
+		switch m := current.(type) {
+		case map[string]interface{}:
+			v, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case Values:
+			v, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
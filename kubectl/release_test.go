@@ -0,0 +1,86 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var _ = Describe("helm releases", func() {
+	var helm *Helm
+
+	BeforeEach(func() {
+		ctx := context.Background()
+		helm = newMemoryHelm(New())
+		_, err := helm.Install(ctx, "elemental-operator", writeChart("1.6.0", ""), HelmOptions{Namespace: "cattle-elemental-system"})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = helm.Upgrade(ctx, "elemental-operator", writeChart("1.7.0", ""), HelmOptions{
+			Namespace: "cattle-elemental-system",
+			Values:    Values{"replicaCount": 2}.Set("image.tag", "v1.7.0"),
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("returns the release with its computed values and history", func() {
+		ctx := context.Background()
+		release, err := helm.Get(ctx, "cattle-elemental-system", "elemental-operator")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(release.Values).To(HaveLen(2))
+		Expect(release.ComputedValues).To(HaveKey("image"))
+
+		history, err := helm.History(ctx, "cattle-elemental-system", "elemental-operator")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(history).To(HaveLen(2))
+		Expect(history[0].Status).To(Equal("superseded"))
+		Expect(history[1].AppVersion).To(Equal("1.7.0"))
+		Expect(history[1].Chart).To(Equal("elemental-operator-1.7.0"))
+	})
+
+	It("matches releases", func() {
+		Eventually(helm.Release("cattle-elemental-system", "elemental-operator")).Should(And(
+			BeDeployedRelease(),
+			HaveChartVersion("1.7.0"),
+			HaveAppVersion(HavePrefix("1.")),
+			HaveReleaseValue("image.tag", "v1.7.0"),
+			HaveReleaseValue("replicaCount", 2),
+		))
+
+		values, err := helm.GetValues(context.Background(), "cattle-elemental-system", "elemental-operator", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(values).To(HaveReleaseValue("image.repository", ContainSubstring("elemental")))
+		Expect(values).ToNot(HaveReleaseValue("image.missing", "value"))
+	})
+
+	It("prints the release on failure", func() {
+		release, err := helm.Get(context.Background(), "cattle-elemental-system", "elemental-operator")
+		Expect(err).ToNot(HaveOccurred())
+
+		matcher := HaveChartVersion("1.8.0")
+		Expect(matcher.Match(release)).To(BeFalse())
+		Expect(matcher.FailureMessage(release)).To(And(
+			HavePrefix("Expected release elemental-operator to have chart version=1.8.0, observed chart version 1.7.0"),
+			ContainSubstring("chart: elemental-operator-1.7.0"),
+			ContainSubstring("tag: v1.7.0"),
+		))
+
+		_, err = BeDeployedRelease().Match("elemental-operator")
+		Expect(err).To(MatchError(ContainSubstring("expected a Helm release")))
+	})
+})
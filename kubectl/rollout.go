@@ -0,0 +1,301 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// failedPodLogLines is the number of log lines of the failed pods reported
+const failedPodLogLines = 20
+
+// rolledOut is the rollout status of a workload, like kubectl rollout status
+type rolledOut struct {
+	kind string
+}
+
+// RolledOut matches Deployments, StatefulSets, ReplicaSets and DaemonSets
+// whose rollout is complete, like kubectl rollout status. A Deployment which
+// exceeded its progress deadline stops the wait.
+func RolledOut() WaitCondition {
+	return &rolledOut{}
+}
+
+func (r *rolledOut) Match(obj *unstructured.Unstructured) (bool, error) {
+	done, _, err := rolloutStatus(obj)
+	return done, err
+}
+
+func (r *rolledOut) Observe(obj *unstructured.Unstructured) string {
+	_, status, err := rolloutStatus(obj)
+	if err != nil {
+		return err.Error()
+	}
+	return status
+}
+
+func (r *rolledOut) String() string {
+	return "rollout"
+}
+
+// rolloutStatus returns true if the rollout of the workload is complete,
+// with a description of its progress
+func rolloutStatus(obj *unstructured.Unstructured) (bool, string, error) {
+	switch obj.GetKind() {
+	case "Deployment":
+		d := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, d); err != nil {
+			return false, "", err
+		}
+		return deploymentRolledOut(d)
+	case "StatefulSet":
+		s := &appsv1.StatefulSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, s); err != nil {
+			return false, "", err
+		}
+		done, status := statefulSetRolledOut(s)
+		return done, status, nil
+	case "ReplicaSet":
+		rs := &appsv1.ReplicaSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, rs); err != nil {
+			return false, "", err
+		}
+		replicas := ptrValue(rs.Spec.Replicas, 1)
+		status := fmt.Sprintf("%d of %d replicas ready, %d available", rs.Status.ReadyReplicas, replicas, rs.Status.AvailableReplicas)
+		return rs.Status.ObservedGeneration >= rs.Generation && rs.Status.ReadyReplicas >= replicas && rs.Status.AvailableReplicas >= replicas, status, nil
+	case "DaemonSet":
+		desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+		available, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+		return daemonSetRolledOut(obj), fmt.Sprintf("%d of %d pods available", available, desired), nil
+	}
+	return false, "", errors.Errorf("no rollout status for %s", obj.GetKind())
+}
+
+// deploymentRolledOut follows the checks of kubectl rollout status
+func deploymentRolledOut(d *appsv1.Deployment) (bool, string, error) {
+	if d.Status.ObservedGeneration < d.Generation {
+		return false, "waiting for the deployment spec update to be observed", nil
+	}
+
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, "", errors.Errorf("deployment %s exceeded its progress deadline", d.Name)
+		}
+	}
+
+	replicas := ptrValue(d.Spec.Replicas, 1)
+	switch {
+	case d.Status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d out of %d new replicas have been updated", d.Status.UpdatedReplicas, replicas), nil
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas), nil
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas), nil
+	}
+	return true, fmt.Sprintf("%d replicas rolled out", replicas), nil
+}
+
+// statefulSetRolledOut follows the checks of kubectl rollout status
+func statefulSetRolledOut(s *appsv1.StatefulSet) (bool, string) {
+	if s.Status.ObservedGeneration < s.Generation {
+		return false, "waiting for the statefulset spec update to be observed"
+	}
+
+	replicas := ptrValue(s.Spec.Replicas, 1)
+	if s.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d of %d replicas ready", s.Status.ReadyReplicas, replicas)
+	}
+
+	if s.Spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType && s.Spec.UpdateStrategy.RollingUpdate != nil {
+		if partition := ptrValue(s.Spec.UpdateStrategy.RollingUpdate.Partition, 0); partition > 0 {
+			if s.Status.UpdatedReplicas < replicas-partition {
+				return false, fmt.Sprintf("%d of %d replicas updated above partition %d", s.Status.UpdatedReplicas, replicas-partition, partition)
+			}
+			return true, fmt.Sprintf("partitioned rollout complete, %d replicas updated", s.Status.UpdatedReplicas)
+		}
+	}
+
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d of %d replicas updated to revision %s", s.Status.UpdatedReplicas, replicas, s.Status.UpdateRevision)
+	}
+	return true, fmt.Sprintf("%d replicas rolled out at revision %s", replicas, s.Status.CurrentRevision)
+}
+
+// ptrValue returns the pointed value, or def if nil
+func ptrValue[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// WaitForRollout blocks until the rollout of the Deployment, StatefulSet,
+// ReplicaSet or DaemonSet is complete, resource is e.g. "deployments" or
+// "statefulsets.apps"
+func (k *Kubectl) WaitForRollout(namespace, resource, name string) error {
+	return k.WaitUntil(namespace, resource, WatchOptions{Name: name}, RolledOut())
+}
+
+// WaitForDeployment blocks until the rollout of the deployment is complete
+func (k *Kubectl) WaitForDeployment(namespace, name string) error {
+	return k.WaitForRollout(namespace, "deployments.apps", name)
+}
+
+// WaitForStatefulSet blocks until the rollout of the statefulset is complete
+func (k *Kubectl) WaitForStatefulSet(namespace, name string) error {
+	return k.WaitForRollout(namespace, "statefulsets.apps", name)
+}
+
+// WaitForReplicaSet blocks until all the replicas of the replicaset are available
+func (k *Kubectl) WaitForReplicaSet(namespace, name string) error {
+	return k.WaitForRollout(namespace, "replicasets.apps", name)
+}
+
+// JobResult is the outcome of a job
+type JobResult struct {
+	Name      string
+	Succeeded int32
+	Failed    int32
+	Active    int32
+}
+
+// WaitForJob blocks until the job completes or fails. A failed job returns
+// an error with the succeeded and failed counts and the last log lines of
+// the failed pods.
+func (k *Kubectl) WaitForJob(namespace, name string) (*JobResult, error) {
+	var job *batchv1.Job
+	err := k.WatchFor(namespace, "jobs.batch", WatchOptions{Name: name}, func(objs []*unstructured.Unstructured) (bool, error) {
+		if len(objs) == 0 {
+			return false, nil
+		}
+
+		j := &batchv1.Job{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(objs[0].Object, j); err != nil {
+			return false, err
+		}
+		job = j
+		return jobFinished(j) != "", nil
+	})
+
+	result := &JobResult{Name: name}
+	if job != nil {
+		result.Succeeded = job.Status.Succeeded
+		result.Failed = job.Status.Failed
+		result.Active = job.Status.Active
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if jobFinished(job) == batchv1.JobFailed {
+		return result, errors.Errorf("job %s failed (%d succeeded, %d failed): %s\n%s",
+			name, result.Succeeded, result.Failed, jobFailureReason(job), k.failedJobLogs(job))
+	}
+	return result, nil
+}
+
+// WaitForCronJob blocks until the next job of the cronjob finishes, jobs
+// which were created before are ignored. See WaitForJob.
+func (k *Kubectl) WaitForCronJob(namespace, name string) (*JobResult, error) {
+	before := map[string]bool{}
+	jobs, err := List[batchv1.Job](k, namespace, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		before[j.Name] = true
+	}
+
+	var jobName string
+	err = k.WatchFor(namespace, "jobs.batch", WatchOptions{}, func(objs []*unstructured.Unstructured) (bool, error) {
+		for _, obj := range objs {
+			if before[obj.GetName()] {
+				continue
+			}
+			for _, owner := range obj.GetOwnerReferences() {
+				if owner.Kind == "CronJob" && owner.Name == name {
+					jobName = obj.GetName()
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for a job of cronjob %s", name)
+	}
+
+	return k.WaitForJob(namespace, jobName)
+}
+
+// jobFinished returns the finished condition of the job, empty if running
+func jobFinished(job *batchv1.Job) batchv1.JobConditionType {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return c.Type
+		}
+	}
+	return ""
+}
+
+// jobFailureReason returns the reason of the failed condition
+func jobFailureReason(job *batchv1.Job) string {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed {
+			return strings.TrimSpace(c.Reason + " " + c.Message)
+		}
+	}
+	return ""
+}
+
+// failedJobLogs returns the last log lines of the failed pods of the job
+func (k *Kubectl) failedJobLogs(job *batchv1.Job) string {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil || job.Spec.Selector == nil {
+		selector, _ = metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"job-name": job.Name}})
+	}
+
+	pods, err := List[corev1.Pod](k, job.Namespace, ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return fmt.Sprintf("cannot list the pods of the job: %s", err)
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	var out strings.Builder
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		for _, container := range logContainers(pod, LogOptions{}) {
+			logs, err := k.GetPodLogs(pod.Namespace, pod.Name, LogOptions{Container: container, TailLines: failedPodLogLines})
+			if err != nil {
+				logs = err.Error() + "\n"
+			}
+			fmt.Fprintf(&out, "--- logs of %s/%s ---\n%s", pod.Name, container, logs)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+func deployment(updated, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "elemental-operator", Namespace: "cattle-elemental-system", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &[]int32{2}[0]},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           2,
+			UpdatedReplicas:    updated,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func job(name string, conditionType batchv1.JobConditionType, succeeded, failed int32) *batchv1.Job {
+	j := &batchv1.Job{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: batchv1.JobSpec{Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"batch.kubernetes.io/job-name": name},
+		}},
+		Status: batchv1.JobStatus{Succeeded: succeeded, Failed: failed},
+	}
+	if conditionType != "" {
+		j.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}}
+	}
+	return j
+}
+
+var _ = Describe("rollouts", func() {
+	It("waits for deployments to be rolled out", func() {
+		k := newFakeKubectl(deployment(1, 1))
+
+		updateLater(k, appsv1.SchemeGroupVersion.WithResource("deployments"), deployment(2, 2))
+		Expect(k.WaitForDeployment("cattle-elemental-system", "elemental-operator")).To(Succeed())
+	})
+
+	It("stops when the deployment exceeded its progress deadline", func() {
+		d := deployment(1, 1)
+		d.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type:   appsv1.DeploymentProgressing,
+			Status: corev1.ConditionFalse,
+			Reason: "ProgressDeadlineExceeded",
+		}}
+		k := newFakeKubectl(d)
+
+		start := time.Now()
+		err := k.WaitForDeployment("cattle-elemental-system", "elemental-operator")
+		Expect(err).To(MatchError(ContainSubstring("exceeded its progress deadline")))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("reports the progress of statefulsets on timeout", func() {
+		k := newFakeKubectl(&appsv1.StatefulSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: "rancher", Namespace: "default"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &[]int32{1}[0]},
+			Status: appsv1.StatefulSetStatus{
+				ReadyReplicas:   1,
+				CurrentRevision: "rancher-1",
+				UpdateRevision:  "rancher-2",
+			},
+		})
+		k.PollTimeout = 200 * time.Millisecond
+
+		err := k.WaitForStatefulSet("default", "rancher")
+		Expect(err).To(MatchError(And(ContainSubstring("timed out"), ContainSubstring("0 of 1 replicas updated to revision rancher-2"))))
+	})
+
+	It("waits for replicasets", func() {
+		k := newFakeKubectl(&appsv1.ReplicaSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: "operator", Namespace: "default"},
+			Status:     appsv1.ReplicaSetStatus{ReadyReplicas: 1, AvailableReplicas: 1},
+		})
+		Expect(k.WaitForReplicaSet("default", "operator")).To(Succeed())
+	})
+})
+
+var _ = Describe("jobs", func() {
+	It("returns the counts of completed jobs", func() {
+		k := newFakeKubectl(job("upgrade", "", 0, 0))
+
+		updateLater(k, batchv1.SchemeGroupVersion.WithResource("jobs"), job("upgrade", batchv1.JobComplete, 1, 2))
+		result, err := k.WaitForJob("default", "upgrade")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(&JobResult{Name: "upgrade", Succeeded: 1, Failed: 2}))
+	})
+
+	It("reports the logs of failed jobs", func() {
+		failed := operatorPod("upgrade-abcde")
+		failed.Labels = map[string]string{"batch.kubernetes.io/job-name": "upgrade"}
+		failed.Status.Phase = corev1.PodFailed
+		succeeded := operatorPod("upgrade-fghij")
+		succeeded.Labels = failed.Labels
+		succeeded.Status.Phase = corev1.PodSucceeded
+
+		k := newFakeKubectl(job("upgrade", batchv1.JobFailed, 0, 1), failed, succeeded)
+
+		result, err := k.WaitForJob("default", "upgrade")
+		Expect(result.Failed).To(Equal(int32(1)))
+		Expect(err).To(MatchError(And(
+			ContainSubstring("job upgrade failed (0 succeeded, 1 failed): BackoffLimitExceeded"),
+			ContainSubstring("--- logs of upgrade-abcde/operator ---\nfake logs"),
+		)))
+		Expect(err.Error()).ToNot(ContainSubstring("upgrade-fghij"))
+	})
+
+	It("waits for the next job of a cronjob", func() {
+		owner := metav1.OwnerReference{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", UID: "1"}
+		previous := job("backup-1", batchv1.JobComplete, 1, 0)
+		previous.OwnerReferences = []metav1.OwnerReference{owner}
+		k := newFakeKubectl(previous)
+
+		next := job("backup-2", batchv1.JobComplete, 1, 0)
+		next.OwnerReferences = []metav1.OwnerReference{owner}
+		updateLater(k, batchv1.SchemeGroupVersion.WithResource("jobs"), next)
+
+		result, err := k.WaitForCronJob("default", "backup")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Name).To(Equal("backup-2"))
+	})
+})
@@ -0,0 +1,167 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"os"
+	"sync"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// KeepOnFailureEnv keeps the tracked objects of failed specs when set to
+// "true", the default of Tracker.KeepOnFailure
+const KeepOnFailureEnv = "ELE_KEEP_ON_FAILURE"
+
+// Kinds of the objects created by the Kubectl helpers
+var (
+	namespaceKind      = schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	secretKind         = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	serviceAccountKind = schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}
+	roleBindingKind    = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}
+)
+
+// Tracker records the objects created through a Kubectl and deletes them
+// once the spec is done
+type Tracker struct {
+	// KeepOnFailure keeps the objects when the spec failed, to debug it
+	KeepOnFailure bool
+
+	k    *Kubectl
+	mu   sync.Mutex
+	refs []ObjectRef
+}
+
+// Track records the objects created from now on by CreateNamespace,
+// CreateSecretFromLiteral, CreateServiceAccount, CreateRoleBinding and
+// ApplyManifest, and registers their deletion with DeferCleanup. It must be
+// called from a Ginkgo node, e.g. BeforeEach, the objects created in the
+// spec are then deleted after it.
+func (k *Kubectl) Track() *Tracker {
+	t := &Tracker{
+		KeepOnFailure: os.Getenv(KeepOnFailureEnv) == "true",
+		k:             k,
+	}
+
+	k.trackerMu.Lock()
+	k.tracker = t
+	k.trackerMu.Unlock()
+
+	ginkgo.DeferCleanup(func() error {
+		k.trackerMu.Lock()
+		if k.tracker == t {
+			k.tracker = nil
+		}
+		k.trackerMu.Unlock()
+
+		if t.KeepOnFailure && ginkgo.CurrentSpecReport().Failed() {
+			ginkgo.GinkgoWriter.Printf("Keeping the objects of the failed spec: %v\n", t.Objects())
+			return nil
+		}
+		return t.Cleanup()
+	})
+
+	return t
+}
+
+// record adds the object to the tracker of the instance, if any
+func (k *Kubectl) record(ref ObjectRef) {
+	k.trackerMu.Lock()
+	t := k.tracker
+	k.trackerMu.Unlock()
+
+	if t != nil {
+		t.Add(ref)
+	}
+}
+
+// Add records an object to delete
+func (t *Tracker) Add(ref ObjectRef) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refs = append(t.refs, ref)
+}
+
+// Objects returns the recorded objects, in creation order
+func (t *Tracker) Objects() []ObjectRef {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]ObjectRef(nil), t.refs...)
+}
+
+// Cleanup deletes the recorded objects in reverse creation order, each
+// deletion waits for the object to be gone so finalizers are run before
+// deleting the objects it depends on. All the objects are tried, the ones
+// which failed are kept to be retried and their errors are aggregated.
+func (t *Tracker) Cleanup() error {
+	t.mu.Lock()
+	refs := t.refs
+	t.refs = nil
+	t.mu.Unlock()
+
+	c, err := t.k.client()
+	if err != nil {
+		t.keep(refs)
+		return err
+	}
+
+	var (
+		errs   []error
+		failed []ObjectRef
+	)
+	for i := len(refs) - 1; i >= 0; i-- {
+		if err := t.delete(c, refs[i]); err != nil {
+			errs = append(errs, err)
+			failed = append([]ObjectRef{refs[i]}, failed...)
+		}
+	}
+	t.keep(failed)
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// keep records again objects which were not deleted, before the objects
+// added in the meantime
+func (t *Tracker) keep(refs []ObjectRef) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refs = append(refs, t.refs...)
+}
+
+// delete deletes the object and waits for it to be gone
+func (t *Tracker) delete(c *ClientBackend, ref ObjectRef) error {
+	if err := c.deleteRef(ref); err != nil {
+		return err
+	}
+
+	m, err := c.restMapping(ref.GroupVersionKind.GroupKind(), ref.GroupVersionKind.Version)
+	if err != nil {
+		return errors.Wrapf(err, "deleting %s", ref)
+	}
+	resource := m.Resource.Resource
+	if m.Resource.Group != "" {
+		resource += "." + m.Resource.Version + "." + m.Resource.Group
+	}
+	if err := t.k.WatchFor(ref.Namespace, resource, WatchOptions{Name: ref.Name}, noObject); err != nil {
+		return errors.Wrapf(err, "waiting for %s deletion", ref)
+	}
+	return nil
+}
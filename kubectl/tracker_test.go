@@ -0,0 +1,104 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"errors"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var _ = Describe("tracker", Ordered, func() {
+	var k *Kubectl
+
+	BeforeAll(func() {
+		k = newFakeKubectl()
+	})
+
+	It("records the created objects", func() {
+		t := k.Track()
+
+		Expect(k.CreateNamespace("tracked")).To(Succeed())
+		Expect(k.CreateSecretFromLiteral("tracked", "registration", map[string]string{"token": "secret"})).To(Succeed())
+		Expect(k.CreateServiceAccount("tracked", "elemental")).To(Succeed())
+		Expect(k.CreateRoleBinding("tracked", "admin", "tracked:elemental", "elemental-admin")).To(Succeed())
+
+		var created []string
+		for _, ref := range t.Objects() {
+			created = append(created, ref.String())
+		}
+		Expect(created).To(Equal([]string{
+			"Namespace tracked",
+			"Secret tracked/registration",
+			"ServiceAccount tracked/elemental",
+			"RoleBinding tracked/elemental-admin",
+		}))
+	})
+
+	It("deleted the objects after the previous spec", func() {
+		Expect(k.Exists("", "namespace", "tracked")).To(BeFalse())
+		Expect(k.SecretExists("tracked", "registration")).To(BeFalse())
+		Expect(k.Exists("tracked", "serviceaccount", "elemental")).To(BeFalse())
+		Expect(k.Exists("tracked", "rolebindings.v1.rbac.authorization.k8s.io", "elemental-admin")).To(BeFalse())
+	})
+
+	It("does not record objects once the spec is done", func() {
+		Expect(k.CreateNamespace("untracked")).To(Succeed())
+		Expect(k.Exists("", "namespace", "untracked")).To(BeTrue())
+	})
+
+	It("can be cleaned up explicitly", func() {
+		t := k.Track()
+
+		Expect(k.CreateNamespace("explicit")).To(Succeed())
+		Expect(t.Cleanup()).To(Succeed())
+		Expect(t.Objects()).To(BeEmpty())
+		Expect(k.Exists("", "namespace", "explicit")).To(BeFalse())
+	})
+
+	It("deletes all the objects and keeps the failed ones", func() {
+		t := k.Track()
+
+		var failing atomic.Bool
+		failing.Store(true)
+		dyn := k.Backend.(*ClientBackend).Dynamic.(*dynamicfake.FakeDynamicClient)
+		dyn.PrependReactor("delete", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+			if failing.Load() {
+				return true, nil, errors.New("deletion refused")
+			}
+			return false, nil, nil
+		})
+
+		Expect(k.CreateNamespace("partial")).To(Succeed())
+		Expect(k.CreateSecretFromLiteral("partial", "registration", map[string]string{"token": "secret"})).To(Succeed())
+		Expect(k.CreateServiceAccount("partial", "elemental")).To(Succeed())
+
+		Expect(t.Cleanup()).To(MatchError(ContainSubstring("deletion refused")))
+		Expect(t.Objects()).To(ConsistOf(HaveField("Name", "registration")))
+		Expect(k.Exists("partial", "serviceaccount", "elemental")).To(BeFalse())
+		Expect(k.Exists("", "namespace", "partial")).To(BeFalse())
+
+		failing.Store(false)
+		Expect(t.Cleanup()).To(Succeed())
+		Expect(t.Objects()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,173 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// Scheme maps the Go types to their Kubernetes kinds for Get and List, it
+// knows the k8s.io/api types, other types (e.g. Elemental CRDs) can be
+// added with their AddToScheme function
+var Scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(Scheme))
+}
+
+// ListOptions selects the objects to list
+type ListOptions struct {
+	LabelSelector string
+	FieldSelector string
+}
+
+// Get returns the object of type T, T must be registered in Scheme (e.g.
+// corev1.Pod). A missing object returns a NotFound error, which can be
+// checked with apierrors.IsNotFound.
+func Get[T any](k *Kubectl, namespace, name string) (*T, error) {
+	c, m, err := mappingFor[T](k)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	obj, err := c.resourceForMapping(namespace, m).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return decodeUnstructured[T](obj)
+}
+
+// List returns the objects of type T, T is the type of the items (e.g.
+// corev1.Pod) and must be registered in Scheme. An empty namespace lists
+// the objects of all the namespaces.
+func List[T any](k *Kubectl, namespace string, opts ListOptions) ([]T, error) {
+	c, m, err := mappingFor[T](k)
+	if err != nil {
+		return nil, err
+	}
+
+	r := c.Dynamic.Resource(m.Resource)
+	if m.Scope.Name() == meta.RESTScopeNameNamespace && namespace != "" {
+		return listObjects[T](c, r.Namespace(namespace), opts)
+	}
+	return listObjects[T](c, r, opts)
+}
+
+// GetResource returns the resource ('pods', 'cluster.v1.provisioning.cattle.io'...)
+// decoded into T, which can be unstructured.Unstructured or any type with
+// JSON tags matching the object.
+func GetResource[T any](k *Kubectl, namespace, resource, name string) (*T, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := c.get(namespace, resource, name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUnstructured[T](obj)
+}
+
+// ListResource returns the resources decoded into T, like GetResource
+func ListResource[T any](k *Kubectl, namespace, resource string, opts ListOptions) ([]T, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := c.mapping(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	r := c.Dynamic.Resource(m.Resource)
+	if m.Scope.Name() == meta.RESTScopeNameNamespace && namespace != "" {
+		return listObjects[T](c, r.Namespace(namespace), opts)
+	}
+	return listObjects[T](c, r, opts)
+}
+
+// mappingFor returns the REST mapping of the type T
+func mappingFor[T any](k *Kubectl) (*ClientBackend, *meta.RESTMapping, error) {
+	obj, ok := any(new(T)).(runtime.Object)
+	if !ok {
+		return nil, nil, errors.Errorf("%T is not a Kubernetes object, use GetResource or ListResource", *new(T))
+	}
+	if _, ok := obj.(runtime.Unstructured); ok {
+		return nil, nil, errors.New("the kind of unstructured objects is unknown, use GetResource or ListResource")
+	}
+
+	gvks, _, err := Scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := k.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := c.restMapping(gvks[0].GroupKind(), gvks[0].Version)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unknown kind %s", gvks[0])
+	}
+	return c, m, nil
+}
+
+// listObjects lists the objects of the resource client and decodes them
+func listObjects[T any](c *ClientBackend, r dynamic.ResourceInterface, opts ListOptions) ([]T, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	list, err := r.List(ctx, metav1.ListOptions{LabelSelector: opts.LabelSelector, FieldSelector: opts.FieldSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]T, 0, len(list.Items))
+	for i := range list.Items {
+		item, err := decodeUnstructured[T](&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// decodeUnstructured converts the object to T
+func decodeUnstructured[T any](obj *unstructured.Unstructured) (*T, error) {
+	out := new(T)
+	if u, ok := any(out).(*unstructured.Unstructured); ok {
+		obj.DeepCopyInto(u)
+		return out, nil
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, out); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s %s", obj.GetKind(), obj.GetName())
+	}
+	return out, nil
+}
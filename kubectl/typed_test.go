@@ -0,0 +1,94 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var _ = Describe("typed getters", func() {
+	var k *Kubectl
+
+	BeforeEach(func() {
+		other := readyPod("other")
+		other.Namespace = "kube-system"
+		other.Labels = map[string]string{"app": "other"}
+		k = newFakeKubectl(readyPod("test-1"), readyPod("test-2"), other, &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"},
+			Data:       map[string]string{"key": "value"},
+		})
+	})
+
+	It("gets typed objects", func() {
+		pod, err := Get[corev1.Pod](k, "default", "test-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pod.Name).To(Equal("test-1"))
+		Expect(pod.Status.Phase).To(Equal(corev1.PodRunning))
+		Expect(pod.Status.ContainerStatuses[0].State.Running).ToNot(BeNil())
+
+		cm, err := Get[corev1.ConfigMap](k, "default", "config")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cm.Data).To(HaveKeyWithValue("key", "value"))
+
+		_, err = Get[corev1.Pod](k, "default", "missing")
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("lists typed objects with selectors", func() {
+		pods, err := List[corev1.Pod](k, "default", ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods).To(HaveLen(2))
+
+		pods, err = List[corev1.Pod](k, "", ListOptions{LabelSelector: "app=other"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods).To(HaveLen(1))
+		Expect(pods[0].Namespace).To(Equal("kube-system"))
+	})
+
+	It("gets resources as unstructured or custom types", func() {
+		u, err := GetResource[unstructured.Unstructured](k, "default", "configmap", "config")
+		Expect(err).ToNot(HaveOccurred())
+		value, found, err := unstructured.NestedString(u.Object, "data", "key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(value).To(Equal("value"))
+
+		type partial struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		items, err := ListResource[partial](k, "default", "pods", ListOptions{LabelSelector: "app=test"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(items).To(HaveLen(2))
+		Expect(items[0].Metadata.Name).To(HavePrefix("test-"))
+	})
+
+	It("rejects types without a known kind", func() {
+		_, err := Get[unstructured.Unstructured](k, "default", "config")
+		Expect(err).To(HaveOccurred())
+
+		_, err = List[struct{ Name string }](k, "default", ListOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,228 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// WaitCondition is a condition evaluated on a single object
+type WaitCondition interface {
+	// Match returns true if the object satisfies the condition, an error
+	// stops the wait
+	Match(obj *unstructured.Unstructured) (bool, error)
+	// Observe returns the value of the object evaluated by the condition,
+	// reported when the wait times out
+	Observe(obj *unstructured.Unstructured) string
+	// String describes the condition
+	String() string
+}
+
+// WaitUntil waits until the objects selected by opts satisfy the condition,
+// opts selects a single object by Name or several objects by
+// LabelSelector, all of them must satisfy the condition and at least one
+// must exist. On timeout the error reports the last observed value of each
+// object, e.g.
+//
+//	err := k.WaitUntil("fleet-default", "machineinventories.elemental.cattle.io",
+//		WatchOptions{LabelSelector: "cluster=test"}, ConditionIs("Ready", "True"))
+func (k *Kubectl) WaitUntil(namespace, resource string, opts WatchOptions, condition WaitCondition) error {
+	err := k.watchFor(namespace, resource, opts, func(objs []*unstructured.Unstructured) (bool, error) {
+		if len(objs) == 0 {
+			return false, nil
+		}
+		for _, obj := range objs {
+			if ok, err := condition.Match(obj); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}, func(objs []*unstructured.Unstructured) string {
+		if len(objs) == 0 {
+			return "no object found"
+		}
+		var desc []string
+		for _, obj := range objs {
+			desc = append(desc, fmt.Sprintf("%s: %s", obj.GetName(), condition.Observe(obj)))
+		}
+		return strings.Join(desc, "; ")
+	})
+	if err != nil {
+		return errors.Wrapf(err, "waiting for %s", condition)
+	}
+	return nil
+}
+
+// conditionIs matches a condition of status.conditions
+type conditionIs struct {
+	conditionType string
+	status        string
+}
+
+// ConditionIs matches objects whose condition of the given type has the
+// status, e.g. ConditionIs("Ready", "True"). The type is compared case
+// insensitively like kubectl wait does.
+func ConditionIs(conditionType, status string) WaitCondition {
+	return &conditionIs{conditionType: conditionType, status: status}
+}
+
+func (c *conditionIs) Match(obj *unstructured.Unstructured) (bool, error) {
+	status, found := conditionStatus(obj, c.conditionType)
+	return found && strings.EqualFold(status, c.status), nil
+}
+
+func (c *conditionIs) Observe(obj *unstructured.Unstructured) string {
+	status, found := conditionStatus(obj, c.conditionType)
+	if !found {
+		return fmt.Sprintf("no %s condition", c.conditionType)
+	}
+	return fmt.Sprintf("%s=%s", c.conditionType, status)
+}
+
+func (c *conditionIs) String() string {
+	return fmt.Sprintf("condition %s=%s", c.conditionType, c.status)
+}
+
+// jsonPathEquals matches the value of a JSONPath expression
+type jsonPathEquals struct {
+	path  string
+	value string
+}
+
+// JSONPathEquals matches objects whose JSONPath expression evaluates to
+// value, like kubectl wait --for=jsonpath='{.status.phase}'=Running. The
+// braces of the expression are optional.
+func JSONPathEquals(path, value string) WaitCondition {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	return &jsonPathEquals{path: path, value: value}
+}
+
+// evaluate returns the value of the path, false if it is not found
+func (j *jsonPathEquals) evaluate(obj *unstructured.Unstructured) (string, bool, error) {
+	p := jsonpath.New("wait")
+	if err := p.Parse(j.path); err != nil {
+		return "", false, errors.Wrapf(err, "invalid JSONPath %s", j.path)
+	}
+
+	var buf bytes.Buffer
+	if err := p.Execute(&buf, obj.Object); err != nil {
+		// The fields are missing until the controllers fill them
+		return "", false, nil
+	}
+	return buf.String(), true, nil
+}
+
+func (j *jsonPathEquals) Match(obj *unstructured.Unstructured) (bool, error) {
+	value, found, err := j.evaluate(obj)
+	return found && value == j.value, err
+}
+
+func (j *jsonPathEquals) Observe(obj *unstructured.Unstructured) string {
+	value, found, err := j.evaluate(obj)
+	switch {
+	case err != nil:
+		return err.Error()
+	case !found:
+		return j.path + " not found"
+	}
+	return fmt.Sprintf("%s=%q", j.path, value)
+}
+
+func (j *jsonPathEquals) String() string {
+	return fmt.Sprintf("%s=%q", j.path, j.value)
+}
+
+// celExpression matches a CEL expression
+type celExpression struct {
+	expr    string
+	program cel.Program
+	err     error
+}
+
+// CELExpression matches objects for which the CEL expression is true, the
+// object is available as self, e.g.
+//
+//	self.status.readyReplicas == self.spec.replicas
+//
+// An expression failing to compile stops the wait, one failing to evaluate,
+// e.g. because a field is not set yet, does not match.
+func CELExpression(expr string) WaitCondition {
+	c := &celExpression{expr: expr}
+
+	env, err := cel.NewEnv(cel.Variable("self", cel.DynType))
+	if err != nil {
+		c.err = err
+		return c
+	}
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		c.err = errors.Wrapf(issues.Err(), "invalid CEL expression %s", expr)
+		return c
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		c.err = errors.Errorf("CEL expression %s returns %s instead of a bool", expr, ast.OutputType())
+		return c
+	}
+	c.program, c.err = env.Program(ast)
+	return c
+}
+
+// evaluate returns the value of the expression on the object
+func (c *celExpression) evaluate(obj *unstructured.Unstructured) (interface{}, error) {
+	out, _, err := c.program.Eval(map[string]interface{}{"self": obj.Object})
+	if err != nil {
+		return nil, err
+	}
+	return out.Value(), nil
+}
+
+func (c *celExpression) Match(obj *unstructured.Unstructured) (bool, error) {
+	if c.err != nil {
+		return false, c.err
+	}
+	value, err := c.evaluate(obj)
+	if err != nil {
+		return false, nil
+	}
+	ok, isBool := value.(bool)
+	if !isBool {
+		return false, errors.Errorf("CEL expression %s returned %v instead of a bool", c.expr, value)
+	}
+	return ok, nil
+}
+
+func (c *celExpression) Observe(obj *unstructured.Unstructured) string {
+	if c.err != nil {
+		return c.err.Error()
+	}
+	value, err := c.evaluate(obj)
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (c *celExpression) String() string {
+	return fmt.Sprintf("CEL expression %s", c.expr)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+var _ = Describe("wait conditions", func() {
+	var k *Kubectl
+
+	BeforeEach(func() {
+		pending := readyPod("test-2")
+		pending.Status.Phase = corev1.PodPending
+		pending.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}}
+
+		k = newFakeKubectl(readyPod("test-1"), pending)
+		k.PollTimeout = 300 * time.Millisecond
+	})
+
+	It("waits for a condition", func() {
+		ready := readyPod("test-2")
+		ready.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		updatePodLater(k, ready)
+
+		k.PollTimeout = 2 * time.Second
+		Expect(k.WaitUntil("default", "pods", WatchOptions{Name: "test-2"}, ConditionIs("ready", "True"))).To(Succeed())
+	})
+
+	It("waits for a JSONPath value", func() {
+		Expect(k.WaitUntil("default", "pods", WatchOptions{Name: "test-1"}, JSONPathEquals(".status.phase", "Running"))).To(Succeed())
+		Expect(k.WaitUntil("default", "pods", WatchOptions{Name: "test-1"}, JSONPathEquals("{.status.containerStatuses[0].name}", "test"))).To(Succeed())
+
+		err := k.WaitUntil("default", "pods", WatchOptions{LabelSelector: "app=test"}, JSONPathEquals(".status.phase", "Running"))
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`waiting for {.status.phase}="Running"`),
+			ContainSubstring("timed out"),
+			ContainSubstring(`test-1: {.status.phase}="Running"`),
+			ContainSubstring(`test-2: {.status.phase}="Pending"`),
+		)))
+	})
+
+	It("waits for a CEL expression", func() {
+		Expect(k.WaitUntil("default", "pods", WatchOptions{Name: "test-1"},
+			CELExpression(`self.status.phase == "Running" && self.status.containerStatuses.all(c, c.ready)`))).To(Succeed())
+
+		err := k.WaitUntil("default", "pods", WatchOptions{Name: "test-2"}, CELExpression(`self.status.podIP != ""`))
+		Expect(err).To(MatchError(And(ContainSubstring("timed out"), ContainSubstring("no such key: podIP"))))
+	})
+
+	It("fails on invalid conditions", func() {
+		err := k.WaitUntil("default", "pods", WatchOptions{Name: "test-1"}, CELExpression(`self.status.phase ==`))
+		Expect(err).To(MatchError(ContainSubstring("invalid CEL expression")))
+		Expect(err).ToNot(MatchError(ContainSubstring("timed out")))
+
+		err = k.WaitUntil("default", "pods", WatchOptions{Name: "test-1"}, CELExpression(`self.status.phase`))
+		Expect(err).To(MatchError(ContainSubstring("instead of a bool")))
+	})
+
+	It("reports missing objects", func() {
+		err := k.WaitUntil("default", "pods", WatchOptions{Name: "missing"}, ConditionIs("Ready", "True"))
+		Expect(err).To(MatchError(ContainSubstring("no object found")))
+	})
+
+	It("rejects unknown label filter statuses", func() {
+		Expect(k.WaitLabelFilter("default", "available", "pod", "app=test")).To(MatchError(ContainSubstring("unknown status available")))
+	})
+})
@@ -0,0 +1,258 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+
+	wait "github.com/rancher-sandbox/ele-testhelpers/helpers"
+)
+
+// ListCondition is evaluated on all the watched objects each time one of
+// them changes, objects are sorted by namespace and name
+type ListCondition func(objs []*unstructured.Unstructured) (bool, error)
+
+// WatchOptions selects the objects to watch
+type WatchOptions struct {
+	// Name selects a single object
+	Name string
+	// LabelSelector selects the objects by labels
+	LabelSelector string
+	// Timeout is the maximum time to wait, PollTimeout is used if zero
+	Timeout time.Duration
+}
+
+// WatchFor watches the resources of the namespace matching the options
+// until the condition is true. The objects are listed first, then watched
+// from the listed resourceVersion, the watch is restarted and objects are
+// listed again if the connection is lost. On timeout the error reports the
+// last observed objects. The watch uses a client built from the kubeconfig
+// and context of the Kubectl, if none can be built the kubectl binary backend
+// lists the objects every PollInterval instead.
+func (k *Kubectl) WatchFor(namespace, resource string, opts WatchOptions, condition ListCondition) error {
+	return k.watchFor(namespace, resource, opts, condition, describeObjects)
+}
+
+// watchFor is WatchFor with the description of the objects reported on timeout
+func (k *Kubectl) watchFor(namespace, resource string, opts WatchOptions, condition ListCondition, describe func([]*unstructured.Unstructured) string) error {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return errors.Wrapf(err, "invalid label selector %s", opts.LabelSelector)
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = k.PollTimeout
+	}
+
+	// The watch uses a client built from the kubeconfig and context with
+	// any backend, the kubectl binary polls only if no client can be built
+	c, err := k.client()
+	if err != nil {
+		if b, ok := k.backend().(*binaryBackend); ok {
+			return k.pollFor(b, namespace, resource, opts, selector, timeout, condition, describe)
+		}
+		return errors.Wrapf(err, "watching %s", resource)
+	}
+
+	r, err := c.resource(namespace, resource)
+	if err != nil {
+		return err
+	}
+
+	listOptions := func(o *metav1.ListOptions) {
+		o.LabelSelector = opts.LabelSelector
+		if opts.Name != "" {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", opts.Name).String()
+		}
+	}
+	lw := &cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, o metav1.ListOptions) (runtime.Object, error) {
+			listOptions(&o)
+			return r.List(ctx, o)
+		},
+		WatchFuncWithContext: func(ctx context.Context, o metav1.ListOptions) (watch.Interface, error) {
+			listOptions(&o)
+			return r.Watch(ctx, o)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		store cache.Store
+		last  []*unstructured.Unstructured
+	)
+	check := func() (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		last = storeObjects(store, opts.Name, selector)
+		return condition(last)
+	}
+
+	_, err = watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{},
+		func(s cache.Store) (bool, error) {
+			store = s
+			return check()
+		},
+		func(event watch.Event) (bool, error) {
+			if event.Type == watch.Error {
+				return false, apierrors.FromObject(event.Object)
+			}
+			return check()
+		})
+	if err != nil && ctx.Err() != nil {
+		mu.Lock()
+		defer mu.Unlock()
+		return watchTimeout(timeout, namespace, resource, describe(last))
+	}
+	return err
+}
+
+// pollFor is watchFor for the kubectl binary, the objects are listed every
+// PollInterval
+func (k *Kubectl) pollFor(b *binaryBackend, namespace, resource string, opts WatchOptions, selector labels.Selector, timeout time.Duration, condition ListCondition, describe func([]*unstructured.Unstructured) string) error {
+	var last []*unstructured.Unstructured
+	err := wait.PollImmediate(k.PollInterval, timeout, func() (bool, error) {
+		objs, err := b.list(namespace, resource, opts)
+		if err != nil {
+			return false, err
+		}
+		last = matchingObjects(objs, opts.Name, selector)
+		return condition(last)
+	})
+	if err == wait.ErrWaitTimeout {
+		return watchTimeout(timeout, namespace, resource, describe(last))
+	}
+	return err
+}
+
+// watchTimeout is the error of a timed out watch
+func watchTimeout(timeout time.Duration, namespace, resource, state string) error {
+	return errors.Errorf("timed out after %s waiting for %s in namespace '%s', last observed state: %s",
+		timeout, resource, namespace, state)
+}
+
+// storeObjects returns the sorted objects of the store matching the name
+// and selector, the fake clients do not filter watch events
+func storeObjects(store cache.Store, name string, selector labels.Selector) []*unstructured.Unstructured {
+	var objs []*unstructured.Unstructured
+	for _, o := range store.List() {
+		if obj, ok := o.(*unstructured.Unstructured); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return matchingObjects(objs, name, selector)
+}
+
+// matchingObjects returns the sorted objects matching the name and selector
+func matchingObjects(all []*unstructured.Unstructured, name string, selector labels.Selector) []*unstructured.Unstructured {
+	var objs []*unstructured.Unstructured
+	for _, obj := range all {
+		if name != "" && obj.GetName() != name {
+			continue
+		}
+		if !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].GetNamespace() != objs[j].GetNamespace() {
+			return objs[i].GetNamespace() < objs[j].GetNamespace()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+	return objs
+}
+
+// describeObjects summarizes the name and status of the objects
+func describeObjects(objs []*unstructured.Unstructured) string {
+	if len(objs) == 0 {
+		return "no object found"
+	}
+
+	var desc []string
+	for _, obj := range objs {
+		status := "{}"
+		if s, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "status"); found {
+			if data, err := json.Marshal(s); err == nil {
+				status = string(data)
+			}
+		}
+		desc = append(desc, fmt.Sprintf("%s status=%s", obj.GetName(), status))
+	}
+	return strings.Join(desc, "; ")
+}
+
+// conditionStatus returns the status of the condition of the object, the
+// type is compared case insensitively like kubectl wait does
+func conditionStatus(obj *unstructured.Unstructured, conditionType string) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if t, _ := cond["type"].(string); strings.EqualFold(t, conditionType) {
+			s, _ := cond["status"].(string)
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// daemonSetRolledOut returns true if the daemonset rollout is complete,
+// like kubectl rollout status does
+func daemonSetRolledOut(obj *unstructured.Unstructured) bool {
+	generation := obj.GetGeneration()
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+
+	return observed >= generation && updated >= desired && available >= desired
+}
+
+// anyObject is true when at least one object is watched
+func anyObject(objs []*unstructured.Unstructured) (bool, error) {
+	return len(objs) > 0, nil
+}
+
+// noObject is true when no object is watched
+func noObject(objs []*unstructured.Unstructured) (bool, error) {
+	return len(objs) == 0, nil
+}
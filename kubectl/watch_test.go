@@ -0,0 +1,206 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// applyLater applies the object after a short delay, while a watch is running
+func applyLater(k *Kubectl, obj interface{}) {
+	data, err := json.Marshal(obj)
+	Expect(err).ToNot(HaveOccurred())
+
+	go func() {
+		defer GinkgoRecover()
+		time.Sleep(100 * time.Millisecond)
+		Expect(k.Backend.Apply("", data)).To(Succeed())
+	}()
+}
+
+// updatePodLater replaces the pod after a short delay, the fake clients
+// do not force server-side apply conflicts on status fields
+func updatePodLater(k *Kubectl, pod *corev1.Pod) {
+	updateLater(k, corev1.SchemeGroupVersion.WithResource("pods"), pod)
+}
+
+// updateLater replaces or creates the object after a short delay
+func updateLater(k *Kubectl, resource schema.GroupVersionResource, obj metav1.Object) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	Expect(err).ToNot(HaveOccurred())
+	r := k.Backend.(*ClientBackend).Dynamic.Resource(resource).Namespace(obj.GetNamespace())
+
+	go func() {
+		defer GinkgoRecover()
+		time.Sleep(100 * time.Millisecond)
+		u := &unstructured.Unstructured{Object: content}
+		_, err := r.Update(context.Background(), u, metav1.UpdateOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = r.Create(context.Background(), u, metav1.CreateOptions{})
+		}
+		Expect(err).ToNot(HaveOccurred())
+	}()
+}
+
+var _ = Describe("watch based waiting", func() {
+	It("reacts to objects created during the wait", func() {
+		k := newFakeKubectl()
+
+		applyLater(k, readyPod("test-1"))
+		Expect(k.WaitForPod("default", "app=test", "test")).To(Succeed())
+		Expect(k.WaitForNamespaceWithPod("default", "app=test")).To(Succeed())
+
+		applyLater(k, &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"},
+		})
+		Expect(k.WaitForSecret("default", "secret")).To(Succeed())
+	})
+
+	It("waits for pods to become ready", func() {
+		pod := readyPod("test-1")
+		pod.Status.ContainerStatuses[0].Ready = false
+		k := newFakeKubectl(pod)
+
+		updatePodLater(k, readyPod("test-1"))
+		Expect(k.WaitForNamespaceWithPod("default", "app=test")).To(Succeed())
+	})
+
+	It("waits for conditions", func() {
+		pod := readyPod("test-1")
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}}
+		k := newFakeKubectl(pod)
+
+		ready := readyPod("test-1")
+		ready.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		updatePodLater(k, ready)
+		Expect(k.Wait("default", "ready", "pod/test-1", 2*time.Second)).To(Succeed())
+		Expect(k.Wait("default", "Ready", "pods", 2*time.Second)).To(Succeed())
+	})
+
+	It("waits for data and deletions", func() {
+		k := newFakeKubectl(readyPod("test-1"))
+
+		Expect(k.WaitForData("default", "pod", "test-1", "jsonpath={.status.phase}", "Running")).To(Succeed())
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(100 * time.Millisecond)
+			Expect(k.DeleteResource("default", "pod", "test-1")).To(Succeed())
+		}()
+		Expect(k.WaitForPodDelete("default", "test-1")).To(Succeed())
+	})
+
+	It("reports the last observed state on timeout", func() {
+		pod := readyPod("test-1")
+		pod.Status.Phase = corev1.PodPending
+		pod.Status.ContainerStatuses[0].Ready = false
+		k := newFakeKubectl(pod)
+		k.PollTimeout = 200 * time.Millisecond
+
+		err := k.WaitForNamespaceWithPod("default", "app=test")
+		Expect(err).To(MatchError(And(
+			ContainSubstring("timed out"),
+			ContainSubstring("test-1"),
+			ContainSubstring("phase=Pending"),
+		)))
+
+		err = k.WatchFor("default", "secrets", WatchOptions{Name: "missing"}, func(objs []*unstructured.Unstructured) (bool, error) {
+			return len(objs) > 0, nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("no object found")))
+	})
+
+	It("watches with a client built from the kubeconfig", func() {
+		var watches atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case r.URL.Path == "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case r.URL.Path == "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case r.URL.Path == "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[{"name":"pods","kind":"Pod","namespaced":true,"verbs":["list","watch"]}]}`))
+			case r.URL.Path == "/api/v1/namespaces/default/pods" && r.URL.Query().Get("watch") == "true":
+				// The pod is added by the watch
+				watches.Add(1)
+				_, _ = w.Write([]byte(`{"type":"ADDED","object":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test-1","namespace":"default","resourceVersion":"2","labels":{"app":"test"}}}}` + "\n"))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			case r.URL.Path == "/api/v1/namespaces/default/pods":
+				_, _ = w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"1"},"items":[]}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		kubeconfig := filepath.Join(GinkgoT().TempDir(), "kubeconfig")
+		Expect(os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters: [{name: test, cluster: {server: "`+server.URL+`"}}]
+contexts: [{name: test, context: {cluster: test, user: test}}]
+users: [{name: test, user: {}}]
+current-context: test
+`), 0o600)).To(Succeed())
+
+		k := NewForKubeconfig(kubeconfig, "")
+		k.PollTimeout = 5 * time.Second
+		err := k.WatchFor("default", "pods", WatchOptions{LabelSelector: "app=test"}, func(objs []*unstructured.Unstructured) (bool, error) {
+			return len(objs) == 1 && objs[0].GetName() == "test-1", nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(watches.Load()).To(BeNumerically(">=", 1))
+	})
+
+	It("polls with the kubectl binary without a kubeconfig", func() {
+		dir := GinkgoT().TempDir()
+		script := "#!/bin/sh\necho '{\"apiVersion\":\"v1\",\"kind\":\"List\",\"items\":[{\"apiVersion\":\"v1\",\"kind\":\"Pod\",\"metadata\":{\"name\":\"test-1\",\"labels\":{\"app\":\"test\"}}}]}'\n"
+		Expect(os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755)).To(Succeed())
+		GinkgoT().Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		k := NewForKubeconfig(filepath.Join(dir, "missing"), "")
+		k.PollInterval = 10 * time.Millisecond
+		err := k.WatchFor("default", "pods", WatchOptions{LabelSelector: "app=test"}, func(objs []*unstructured.Unstructured) (bool, error) {
+			return len(objs) == 1 && objs[0].GetName() == "test-1", nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		k.Backend = struct{ Backend }{newFakeKubectl().Backend}
+		err = k.WatchFor("default", "pods", WatchOptions{}, func([]*unstructured.Unstructured) (bool, error) {
+			return true, nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("watching pods")))
+	})
+})
@@ -0,0 +1,363 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	wait "github.com/rancher-sandbox/ele-testhelpers/helpers"
+)
+
+// webhookResources are the resources of the webhook configurations
+var webhookResources = []string{
+	"mutatingwebhookconfigurations.admissionregistration.k8s.io",
+	"validatingwebhookconfigurations.admissionregistration.k8s.io",
+}
+
+// WebhookFilter selects webhook configurations, empty fields match all the
+// configurations
+type WebhookFilter struct {
+	// LabelSelector selects the configurations by label
+	LabelSelector string
+	// ServiceNamespace and ServiceName select the configurations with a
+	// webhook calling the service
+	ServiceNamespace string
+	ServiceName      string
+}
+
+// String describes the filter
+func (f WebhookFilter) String() string {
+	var s []string
+	if f.LabelSelector != "" {
+		s = append(s, "labels "+f.LabelSelector)
+	}
+	if f.ServiceName != "" {
+		s = append(s, fmt.Sprintf("service %s/%s", f.ServiceNamespace, f.ServiceName))
+	}
+	if len(s) == 0 {
+		return "all"
+	}
+	return strings.Join(s, ", ")
+}
+
+// Webhook is a webhook of a configuration
+type Webhook struct {
+	Name          string
+	FailurePolicy string
+	// ServiceNamespace and ServiceName are the service called by the
+	// webhook, they are empty for webhooks calling a URL
+	ServiceNamespace string
+	ServiceName      string
+	URL              string
+	// HasCABundle is true once the CA of the webhook is set, e.g. by
+	// cert-manager
+	HasCABundle bool
+}
+
+// WebhookConfiguration is a mutating or validating webhook configuration
+type WebhookConfiguration struct {
+	// Kind is MutatingWebhookConfiguration or ValidatingWebhookConfiguration
+	Kind     string
+	Name     string
+	Webhooks []Webhook
+
+	obj *unstructured.Unstructured
+}
+
+// callsService returns true if a webhook of the configuration calls the
+// service selected by the filter
+func (w *WebhookConfiguration) callsService(f WebhookFilter) bool {
+	for _, h := range w.Webhooks {
+		if h.ServiceName == f.ServiceName && (f.ServiceNamespace == "" || h.ServiceNamespace == f.ServiceNamespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookConfigurationOf decodes the webhooks of the configuration
+func webhookConfigurationOf(obj *unstructured.Unstructured) *WebhookConfiguration {
+	w := &WebhookConfiguration{Kind: obj.GetKind(), Name: obj.GetName(), obj: obj}
+
+	webhooks, _, _ := unstructured.NestedSlice(obj.Object, "webhooks")
+	for _, h := range webhooks {
+		hook, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		webhook := Webhook{}
+		webhook.Name, _, _ = unstructured.NestedString(hook, "name")
+		webhook.FailurePolicy, _, _ = unstructured.NestedString(hook, "failurePolicy")
+		webhook.ServiceNamespace, _, _ = unstructured.NestedString(hook, "clientConfig", "service", "namespace")
+		webhook.ServiceName, _, _ = unstructured.NestedString(hook, "clientConfig", "service", "name")
+		webhook.URL, _, _ = unstructured.NestedString(hook, "clientConfig", "url")
+		caBundle, _, _ := unstructured.NestedString(hook, "clientConfig", "caBundle")
+		webhook.HasCABundle = caBundle != ""
+		w.Webhooks = append(w.Webhooks, webhook)
+	}
+	return w
+}
+
+// ListWebhooks returns the mutating then validating webhook configurations
+// matching the filter
+func (k *Kubectl) ListWebhooks(filter WebhookFilter) ([]*WebhookConfiguration, error) {
+	c, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+
+	var configurations []*WebhookConfiguration
+	for _, resource := range webhookResources {
+		r, err := c.resource("", resource)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := c.context()
+		list, err := r.List(ctx, metav1.ListOptions{LabelSelector: filter.LabelSelector})
+		cancel()
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing %s", resource)
+		}
+
+		for i := range list.Items {
+			w := webhookConfigurationOf(&list.Items[i])
+			if filter.ServiceName == "" || w.callsService(filter) {
+				configurations = append(configurations, w)
+			}
+		}
+	}
+	return configurations, nil
+}
+
+// ServiceEndpointReady returns true if the service has a ready endpoint in
+// its EndpointSlices
+func (k *Kubectl) ServiceEndpointReady(namespace, service string) (bool, error) {
+	c, err := k.client()
+	if err != nil {
+		return false, err
+	}
+
+	r, err := c.resource(namespace, "endpointslices.discovery.k8s.io")
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	slices, err := r.List(ctx, metav1.ListOptions{LabelSelector: "kubernetes.io/service-name=" + service})
+	if err != nil {
+		return false, err
+	}
+	for _, slice := range slices.Items {
+		endpoints, _, _ := unstructured.NestedSlice(slice.Object, "endpoints")
+		for _, e := range endpoints {
+			endpoint, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// A missing ready condition means ready
+			if ready, found, _ := unstructured.NestedBool(endpoint, "conditions", "ready"); !found || ready {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// WaitForWebhooks waits until the webhook configurations matching the
+// filter exist, have their CA bundle and a ready endpoint for the services
+// they call. It avoids the "failed calling webhook" errors right after an
+// operator install.
+func (k *Kubectl) WaitForWebhooks(filter WebhookFilter) error {
+	state := ""
+	err := wait.PollImmediate(k.PollInterval, k.PollTimeout, func() (bool, error) {
+		ready, s, err := k.webhooksReady(filter)
+		state = s
+		return ready, err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "waiting for the webhooks of %s, last observed state: %s", filter, state)
+	}
+	return nil
+}
+
+// webhooksReady returns true if the webhooks are ready, with a description
+// of the webhooks which are not
+func (k *Kubectl) webhooksReady(filter WebhookFilter) (bool, string, error) {
+	configurations, err := k.ListWebhooks(filter)
+	if err != nil {
+		return false, "", err
+	}
+	if len(configurations) == 0 {
+		return false, "no webhook configuration found", nil
+	}
+
+	var pending []string
+	checked := map[string]bool{}
+	for _, w := range configurations {
+		for _, h := range w.Webhooks {
+			if h.ServiceName == "" {
+				continue
+			}
+			if !h.HasCABundle {
+				pending = append(pending, fmt.Sprintf("%s %s has no CA bundle", w.Name, h.Name))
+			}
+
+			service := h.ServiceNamespace + "/" + h.ServiceName
+			if checked[service] {
+				continue
+			}
+			checked[service] = true
+
+			ready, err := k.ServiceEndpointReady(h.ServiceNamespace, h.ServiceName)
+			if err != nil {
+				return false, "", err
+			}
+			if !ready {
+				pending = append(pending, fmt.Sprintf("service %s has no ready endpoint", service))
+			}
+		}
+	}
+	if len(pending) > 0 {
+		return false, strings.Join(pending, "; "), nil
+	}
+	return true, "ready", nil
+}
+
+// SetWebhookFailurePolicy sets the failure policy of the webhooks of the
+// configurations matching the filter, e.g. "Ignore", and restores the
+// previous policies with DeferCleanup. It must be called from a Ginkgo node.
+func (k *Kubectl) SetWebhookFailurePolicy(filter WebhookFilter, policy string) error {
+	configurations, err := k.ListWebhooks(filter)
+	if err != nil {
+		return err
+	}
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	for _, w := range configurations {
+		previous := map[string]string{}
+		for _, h := range w.Webhooks {
+			previous[h.Name] = h.FailurePolicy
+		}
+		if err := c.setFailurePolicies(w, func(string) string { return policy }); err != nil {
+			return err
+		}
+
+		ginkgo.DeferCleanup(func() error {
+			return c.setFailurePolicies(w, func(name string) string { return previous[name] })
+		})
+	}
+	return nil
+}
+
+// setFailurePolicies updates the failure policies of the current version of
+// the configuration, webhooks missing from policy are left untouched
+func (c *ClientBackend) setFailurePolicies(w *WebhookConfiguration, policy func(name string) string) error {
+	r, err := c.objectResource("", w.obj)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	obj, err := r.Get(ctx, w.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "getting %s %s", w.Kind, w.Name)
+	}
+
+	webhooks, _, _ := unstructured.NestedSlice(obj.Object, "webhooks")
+	for _, h := range webhooks {
+		hook, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := hook["name"].(string)
+		if p := policy(name); p != "" {
+			hook["failurePolicy"] = p
+		}
+	}
+	if err := unstructured.SetNestedSlice(obj.Object, webhooks, "webhooks"); err != nil {
+		return err
+	}
+
+	_, err = r.Update(ctx, obj, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "updating %s %s", w.Kind, w.Name)
+}
+
+// RemoveWebhooks deletes the webhook configurations matching the filter and
+// restores them with DeferCleanup, unless their operator recreated them in
+// the meantime. It must be called from a Ginkgo node.
+func (k *Kubectl) RemoveWebhooks(filter WebhookFilter) error {
+	configurations, err := k.ListWebhooks(filter)
+	if err != nil {
+		return err
+	}
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, w := range configurations {
+		if err := c.deleteRef(refOf(w.obj)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		saved := w.obj.DeepCopy()
+		ginkgo.DeferCleanup(func() error {
+			return c.restoreObject(saved)
+		})
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// restoreObject recreates a deleted object, an existing object is kept
+func (c *ClientBackend) restoreObject(obj *unstructured.Unstructured) error {
+	r, err := c.objectResource(obj.GetNamespace(), obj)
+	if err != nil {
+		return err
+	}
+
+	obj = obj.DeepCopy()
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetGeneration(0)
+	obj.SetManagedFields(nil)
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	_, err = r.Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "restoring %s", refOf(obj))
+	}
+	return nil
+}
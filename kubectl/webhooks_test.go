@@ -0,0 +1,141 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+// operatorWebhook returns the validating webhook configuration of the
+// elemental-operator, calling its webhook service
+func operatorWebhook(caBundle string) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	fail := admissionregistrationv1.Fail
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admissionregistration.k8s.io/v1", Kind: "ValidatingWebhookConfiguration"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "elemental-operator-webhook",
+			Labels: map[string]string{"app": "elemental-operator"},
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:          "registrations.elemental.cattle.io",
+			FailurePolicy: &fail,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service:  &admissionregistrationv1.ServiceReference{Namespace: "cattle-elemental-system", Name: "elemental-operator-webhook"},
+				CABundle: []byte(caBundle),
+			},
+		}},
+	}
+}
+
+// webhookEndpoints returns the EndpointSlice of the webhook service
+func webhookEndpoints(ready bool) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		TypeMeta: metav1.TypeMeta{APIVersion: "discovery.k8s.io/v1", Kind: "EndpointSlice"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "elemental-operator-webhook-abcde",
+			Namespace: "cattle-elemental-system",
+			Labels:    map[string]string{"kubernetes.io/service-name": "elemental-operator-webhook"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses:  []string{"10.42.0.12"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+		}},
+	}
+}
+
+var _ = Describe("webhooks", func() {
+	It("lists the webhook configurations by label and service", func() {
+		k := newFakeKubectl(operatorWebhook("ca"))
+
+		webhooks, err := k.ListWebhooks(WebhookFilter{LabelSelector: "app=elemental-operator"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(webhooks).To(HaveLen(1))
+		Expect(webhooks[0].Kind).To(Equal("ValidatingWebhookConfiguration"))
+		Expect(webhooks[0].Webhooks).To(ConsistOf(Webhook{
+			Name:             "registrations.elemental.cattle.io",
+			FailurePolicy:    "Fail",
+			ServiceNamespace: "cattle-elemental-system",
+			ServiceName:      "elemental-operator-webhook",
+			HasCABundle:      true,
+		}))
+
+		webhooks, err = k.ListWebhooks(WebhookFilter{ServiceNamespace: "cattle-elemental-system", ServiceName: "rancher-webhook"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(webhooks).To(BeEmpty())
+	})
+
+	It("waits for the CA bundle and the service endpoint", func() {
+		k := newFakeKubectl(operatorWebhook(""), webhookEndpoints(false))
+
+		updateLater(k, admissionregistrationv1.SchemeGroupVersion.WithResource("validatingwebhookconfigurations"), operatorWebhook("ca"))
+		updateLater(k, discoveryv1.SchemeGroupVersion.WithResource("endpointslices"), webhookEndpoints(true))
+		Expect(k.WaitForWebhooks(WebhookFilter{ServiceName: "elemental-operator-webhook"})).To(Succeed())
+	})
+
+	It("reports the webhooks which are not ready", func() {
+		k := newFakeKubectl(operatorWebhook(""))
+		k.PollTimeout = 200 * time.Millisecond
+
+		err := k.WaitForWebhooks(WebhookFilter{LabelSelector: "app=elemental-operator"})
+		Expect(err).To(MatchError(And(
+			ContainSubstring("registrations.elemental.cattle.io has no CA bundle"),
+			ContainSubstring("service cattle-elemental-system/elemental-operator-webhook has no ready endpoint"),
+		)))
+	})
+
+	Context("with temporary changes", Ordered, func() {
+		var k *Kubectl
+		filter := WebhookFilter{LabelSelector: "app=elemental-operator"}
+
+		BeforeAll(func() {
+			k = newFakeKubectl(operatorWebhook("ca"))
+		})
+
+		It("sets the failure policy", func() {
+			Expect(k.SetWebhookFailurePolicy(filter, "Ignore")).To(Succeed())
+
+			webhooks, err := k.ListWebhooks(filter)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webhooks[0].Webhooks[0].FailurePolicy).To(Equal("Ignore"))
+		})
+
+		It("restored the failure policy after the previous spec", func() {
+			webhooks, err := k.ListWebhooks(filter)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webhooks[0].Webhooks[0].FailurePolicy).To(Equal("Fail"))
+		})
+
+		It("removes the webhooks", func() {
+			Expect(k.RemoveWebhooks(filter)).To(Succeed())
+			Expect(k.ListWebhooks(filter)).To(BeEmpty())
+		})
+
+		It("restored the webhooks after the previous spec", func() {
+			webhooks, err := k.ListWebhooks(filter)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webhooks).To(HaveLen(1))
+			Expect(webhooks[0].Webhooks[0].HasCABundle).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,133 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package media
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/rancher-sandbox/ele-testhelpers/cloudconfig"
+)
+
+const (
+	qemuImgCmd = "qemu-img"
+
+	// Supported disk formats
+	Raw   = "raw"
+	Qcow2 = "qcow2"
+)
+
+// DiskInfo is the subset of 'qemu-img info' output we care about
+type DiskInfo struct {
+	Filename    string `json:"filename"`
+	Format      string `json:"format"`
+	VirtualSize int64  `json:"virtual-size"`
+	ActualSize  int64  `json:"actual-size"`
+	BackingFile string `json:"backing-filename,omitempty"`
+}
+
+// BootMedia are the files needed to boot a VM
+type BootMedia struct {
+	Disk string
+	Seed string
+}
+
+// CreateRawDisk creates a sparse raw disk of the given size in bytes,
+// it does not need qemu-img
+func CreateRawDisk(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "resizing %s", path)
+	}
+
+	return f.Close()
+}
+
+// CreateDisk creates a sparse disk with qemu-img, size uses the qemu-img
+// syntax (e.g. 20G)
+func CreateDisk(path, format, size string) error {
+	out, err := exec.Command(qemuImgCmd, "create", "-f", format, path, size).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "creating %s disk %s: %s", format, path, string(out))
+	}
+	return nil
+}
+
+// CreateOverlay creates a qcow2 disk backed by an existing image, so the
+// image itself is never modified by the VM
+func CreateOverlay(path, backingFile, backingFormat string) error {
+	backingFile, err := filepath.Abs(backingFile)
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.Command(qemuImgCmd, "create", "-f", Qcow2,
+		"-b", backingFile, "-F", backingFormat, path).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "creating overlay %s: %s", path, string(out))
+	}
+	return nil
+}
+
+// GetDiskInfo returns the format and sizes of a disk image
+func GetDiskInfo(path string) (*DiskInfo, error) {
+	out, err := exec.Command(qemuImgCmd, "info", "--output=json", path).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting info of %s", path)
+	}
+
+	info := &DiskInfo{}
+	if err := json.Unmarshal(out, info); err != nil {
+		return nil, errors.Wrapf(err, "invalid json '%s'", string(out))
+	}
+
+	return info, nil
+}
+
+// PrepareBootMedia creates in dir an empty qcow2 disk and a seed ISO built
+// from the cloud-config, ready to be used by a VM
+func PrepareBootMedia(dir string, c *cloudconfig.Config, hostname, diskSize string) (*BootMedia, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	bm := &BootMedia{
+		Disk: filepath.Join(dir, hostname+".qcow2"),
+		Seed: filepath.Join(dir, hostname+"-seed.iso"),
+	}
+
+	if err := CreateDisk(bm.Disk, Qcow2, diskSize); err != nil {
+		return nil, err
+	}
+
+	seed, err := SeedFromConfig(c, hostname, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if err := CreateSeedISO(bm.Seed, seed); err != nil {
+		return nil, err
+	}
+
+	return bm, nil
+}
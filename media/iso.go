@@ -0,0 +1,231 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package media
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kdomanski/iso9660"
+	"github.com/pkg/errors"
+
+	"github.com/rancher-sandbox/ele-testhelpers/cloudconfig"
+)
+
+// CidataLabel is the volume label expected by the NoCloud datasource
+const CidataLabel = "cidata"
+
+// isoTools lists the tools able to build an ISO with Joliet and Rock Ridge
+// extensions, the first one found in PATH is used
+var isoTools = [][]string{
+	{"xorriso", "-as", "mkisofs"},
+	{"genisoimage"},
+	{"mkisofs"},
+}
+
+// Seed is the content of a NoCloud seed ISO
+type Seed struct {
+	UserData      []byte
+	MetaData      []byte
+	NetworkConfig []byte
+	VendorData    []byte
+}
+
+// SeedFromConfig returns a NoCloud seed using the cloud-config as user-data
+func SeedFromConfig(c *cloudconfig.Config, instanceID, hostname string) (*Seed, error) {
+	userData, err := c.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	metaData := fmt.Sprintf("instance-id: %s\n", instanceID)
+	if hostname != "" {
+		metaData += fmt.Sprintf("local-hostname: %s\n", hostname)
+	}
+
+	return &Seed{
+		UserData: userData,
+		MetaData: []byte(metaData),
+	}, nil
+}
+
+// CreateSeedISO writes a NoCloud (cidata) seed ISO to dst
+func CreateSeedISO(dst string, s *Seed) error {
+	if len(s.MetaData) == 0 {
+		return errors.New("meta-data is required by the NoCloud datasource")
+	}
+
+	dir, err := os.MkdirTemp("", "cidata-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"user-data":      s.UserData,
+		"meta-data":      s.MetaData,
+		"network-config": s.NetworkConfig,
+		"vendor-data":    s.VendorData,
+	}
+	for name, data := range files {
+		// user-data and meta-data must always exist, even empty
+		if data == nil && name != "user-data" && name != "meta-data" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return CreateISO(dst, CidataLabel, dir)
+}
+
+// CreateISO builds an ISO with Joliet and Rock Ridge extensions from the
+// content of srcDir, file names are kept as-is
+func CreateISO(dst, label, srcDir string) error {
+	for _, tool := range isoTools {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+
+		args := append([]string{}, tool[1:]...)
+		args = append(args, "-o", dst, "-V", label, "-J", "-R", srcDir)
+		out, err := exec.Command(tool[0], args...).CombinedOutput()
+		if err != nil {
+			return errors.Wrapf(err, "%s failed: %s", tool[0], string(out))
+		}
+		return nil
+	}
+
+	return errors.New("no ISO creation tool found, please install xorriso, genisoimage or mkisofs")
+}
+
+// ISOLabel returns the volume label of an ISO
+func ISOLabel(iso string) (string, error) {
+	img, closer, err := openISO(iso)
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+
+	label, err := img.Label()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(label), nil
+}
+
+// ISOFiles returns the sorted list of files (not directories) of an ISO
+func ISOFiles(iso string) ([]string, error) {
+	img, closer, err := openISO(iso)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	root, err := img.RootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = walkISO(root, "/", func(p string, f *iso9660.File) error {
+		if !f.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadISOFile returns the content of a file stored in an ISO
+func ReadISOFile(iso, name string) ([]byte, error) {
+	img, closer, err := openISO(iso)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	root, err := img.RootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	name = path.Clean("/" + name)
+
+	var file *iso9660.File
+	err = walkISO(root, "/", func(p string, f *iso9660.File) error {
+		if file == nil && !f.IsDir() && strings.EqualFold(p, name) {
+			file = f
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, errors.Errorf("file %s not found in %s", name, iso)
+	}
+
+	return io.ReadAll(file.Reader())
+}
+
+func openISO(iso string) (*iso9660.Image, io.Closer, error) {
+	f, err := os.Open(iso)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, err := iso9660.OpenImage(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, errors.Wrapf(err, "reading ISO %s", iso)
+	}
+
+	return img, f, nil
+}
+
+// walkISO calls fn for each entry below dir
+func walkISO(dir *iso9660.File, prefix string, fn func(string, *iso9660.File) error) error {
+	children, err := dir.GetChildren()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range children {
+		p := path.Join(prefix, c.Name())
+		if err := fn(p, c); err != nil {
+			return err
+		}
+		if c.IsDir() {
+			if err := walkISO(c, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
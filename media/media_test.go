@@ -0,0 +1,116 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package media_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kdomanski/iso9660"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/media"
+)
+
+var _ = Describe("Media tests", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("Creates a sparse raw disk", func() {
+		disk := filepath.Join(dir, "disk.img")
+		Expect(media.CreateRawDisk(disk, 1<<30)).To(Succeed())
+
+		info, err := os.Stat(disk)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(info.Size()).To(BeNumerically("==", 1<<30))
+
+		// Never overwrite an existing disk
+		Expect(media.CreateRawDisk(disk, 1<<30)).To(HaveOccurred())
+	})
+
+	It("Inspects an ISO", func() {
+		iso := filepath.Join(dir, "test.iso")
+
+		w, err := iso9660.NewWriter()
+		Expect(err).To(Not(HaveOccurred()))
+		defer w.Cleanup()
+		Expect(w.AddFile(strings.NewReader("instance-id: test\n"), "meta")).To(Succeed())
+		Expect(w.AddFile(strings.NewReader("#cloud-config\n"), "dir/user")).To(Succeed())
+
+		f, err := os.Create(iso)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(w.WriteTo(f, media.CidataLabel)).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		label, err := media.ISOLabel(iso)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(label).To(Equal(media.CidataLabel))
+
+		files, err := media.ISOFiles(iso)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(files).To(Equal([]string{"/dir/user", "/meta"}))
+
+		data, err := media.ReadISOFile(iso, "dir/user")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(Equal("#cloud-config\n"))
+
+		_, err = media.ReadISOFile(iso, "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Creates a seed ISO", func() {
+		found := false
+		for _, tool := range []string{"xorriso", "genisoimage", "mkisofs"} {
+			if _, err := exec.LookPath(tool); err == nil {
+				found = true
+			}
+		}
+		if !found {
+			Skip("xorriso, genisoimage or mkisofs is required to build an ISO")
+		}
+
+		iso := filepath.Join(dir, "seed.iso")
+		Expect(media.CreateSeedISO(iso, &media.Seed{
+			UserData: []byte("#cloud-config\nhostname: test\n"),
+			MetaData: []byte("instance-id: test\n"),
+		})).To(Succeed())
+
+		label, err := media.ISOLabel(iso)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(label).To(Equal(media.CidataLabel))
+
+		files, err := media.ISOFiles(iso)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(files).To(Equal([]string{"/meta-data", "/user-data"}))
+
+		data, err := media.ReadISOFile(iso, "user-data")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(Equal("#cloud-config\nhostname: test\n"))
+
+		data, err = media.ReadISOFile(iso, "meta-data")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(Equal("instance-id: test\n"))
+	})
+
+	It("Requires meta-data in a seed", func() {
+		err := media.CreateSeedISO(filepath.Join(dir, "seed.iso"), &media.Seed{UserData: []byte("#cloud-config\n")})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -24,6 +24,7 @@ import (
 	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
 	"gopkg.in/yaml.v3"
+	sigsyaml "sigs.k8s.io/yaml"
 )
 
 const noExist = "'%s' does not exist!"
@@ -31,12 +32,13 @@ const noExist = "'%s' does not exist!"
 /**
  * Get cluster informations
  * @remarks Cluster informations are exported to *c
+ * @param k Kubectl context of the Rancher cluster
  * @param ns Namespace
  * @param name Cluster name
  * @returns Cluster informations in *c or an error
  */
-func (c *Cluster) getCluster(ns, name string) error {
-	out, err := kubectl.Run("get",
+func (c *Cluster) getCluster(k *kubectl.Kubectl, ns, name string) error {
+	out, err := k.Run("get",
 		"cluster.v1.provisioning.cattle.io",
 		"--namespace", ns, name,
 		"-o", "yaml")
@@ -50,34 +52,26 @@ func (c *Cluster) getCluster(ns, name string) error {
 
 /**
  * Set/update cluster configuration
- * @remarks Cluster informations are set/exported to *c
+ * @remarks The machine pools of *c are merge patched into the cluster
+ * @param k Kubectl context of the Rancher cluster
  * @param ns Namespace
- * @returns Cluster informations in *c or an error
+ * @returns Nothing or an error
  */
-func (c *Cluster) setCluster(ns string) error {
-	// Encode content
-	out, err := yaml.Marshal(&c)
+func (c *Cluster) setCluster(k *kubectl.Kubectl, ns string) error {
+	// Encode pools with their YAML field names
+	out, err := yaml.Marshal(c.Spec.RkeConfig.MachinePools)
 	if err != nil {
 		return err
 	}
-
-	// Use temporary file
-	f, err := os.CreateTemp("", "updatedCluster")
+	pools, err := sigsyaml.YAMLToJSON(out)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(f.Name())
-
-	if _, err := f.Write(out); err != nil {
-		return err
-	}
-
-	if err := f.Close(); err != nil {
-		return err
-	}
 
-	// Apply new cluster configuration
-	return kubectl.Apply(ns, f.Name())
+	// Only update the pools, the rest of the cluster is kept as is
+	patch := fmt.Sprintf(`{"spec":{"rkeConfig":{"machinePools":%s}}}`, pools)
+	_, err = k.Patch(ns, "cluster.v1.provisioning.cattle.io", c.Metadata.Name, kubectl.MergePatch, patch, kubectl.PatchOptions{})
+	return err
 }
 
 /**
@@ -93,9 +87,10 @@ func SetNodeQuantity(ns, name, pool string, quantity int) (int, error) {
 	c := &Cluster{}
 	quantitySet := 0
 	poolFound := false
+	k := kubectl.New()
 
 	// Get cluster configuration
-	if err := c.getCluster(ns, name); err != nil {
+	if err := c.getCluster(k, ns, name); err != nil {
 		return 0, err
 	}
 
@@ -121,7 +116,7 @@ func SetNodeQuantity(ns, name, pool string, quantity int) (int, error) {
 	}
 
 	// Save and apply cluster configuration
-	if err := c.setCluster(ns); err != nil {
+	if err := c.setCluster(k, ns); err != nil {
 		return 0, err
 	}
 
@@ -142,9 +137,10 @@ func SetNodeQuantity(ns, name, pool string, quantity int) (int, error) {
 func SetRole(ns, name, pool, role string, value bool) error {
 	c := &Cluster{}
 	poolFound := false
+	k := kubectl.New()
 
 	// Get cluster configuration
-	if err := c.getCluster(ns, name); err != nil {
+	if err := c.getCluster(k, ns, name); err != nil {
 		return err
 	}
 
@@ -177,7 +173,7 @@ func SetRole(ns, name, pool, role string, value bool) error {
 	}
 
 	// Save and apply cluster configuration
-	return c.setCluster(ns)
+	return c.setCluster(k, ns)
 }
 
 /**
@@ -216,13 +212,14 @@ func CheckPod(k *kubectl.Kubectl, checkList [][]string) error {
 }
 
 /**
- * Set kubeconfig
- * @remarks This function sets KUBECONFIG env variable to access client cluster
- * @param ns Namesapce
+ * Write kubeconfig
+ * @remarks This function writes the kubeconfig of a client cluster in a temporary file
+ * @param k Kubectl context of the Rancher cluster
+ * @param ns Namespace
  * @param name Cluster name
  * @returns Filename of created client kubeconfig
  */
-func SetClientKubeConfig(ns, name string) (string, error) {
+func writeClientKubeConfig(k *kubectl.Kubectl, ns, name string) (string, error) {
 	// Use our internal CreateTemp function!
 	kubeConfig, err := tools.CreateTemp("clientKubeConfig")
 	if err != nil {
@@ -230,7 +227,7 @@ func SetClientKubeConfig(ns, name string) (string, error) {
 	}
 
 	// Get Kubeconfig of client cluster
-	out, err := kubectl.Run("get", "secret",
+	out, err := k.Run("get", "secret",
 		"--namespace", ns,
 		name+"-kubeconfig", "-o", "jsonpath={.data.value}")
 	if err != nil {
@@ -250,6 +247,43 @@ func SetClientKubeConfig(ns, name string) (string, error) {
 		return "", err
 	}
 
+	return kubeConfig, nil
+}
+
+/**
+ * Get client Kubectl
+ * @remarks This function returns a Kubectl context to access a client cluster, without changing the environment
+ * @param k Kubectl context of the Rancher cluster
+ * @param ns Namespace
+ * @param name Cluster name
+ * @returns Kubectl context of the client cluster, its Kubeconfig file must be removed by the caller
+ * @example client, err := rancher.GetClientKubectl(k, clusterNS, clusterName); defer os.Remove(client.Kubeconfig)
+ */
+func GetClientKubectl(k *kubectl.Kubectl, ns, name string) (*kubectl.Kubectl, error) {
+	kubeConfig, err := writeClientKubeConfig(k, ns, name)
+	if err != nil {
+		return nil, err
+	}
+
+	client := kubectl.NewForKubeconfig(kubeConfig, "")
+	client.PollTimeout = k.PollTimeout
+	client.PollInterval = k.PollInterval
+	return client, nil
+}
+
+/**
+ * Set kubeconfig
+ * @remarks This function sets KUBECONFIG env variable to access client cluster, prefer GetClientKubectl in parallel specs
+ * @param ns Namesapce
+ * @param name Cluster name
+ * @returns Filename of created client kubeconfig
+ */
+func SetClientKubeConfig(ns, name string) (string, error) {
+	kubeConfig, err := writeClientKubeConfig(kubectl.New(), ns, name)
+	if err != nil {
+		return "", err
+	}
+
 	// Export KUBECONFIG envar
 	err = os.Setenv("KUBECONFIG", kubeConfig)
 	if err != nil {
@@ -0,0 +1,503 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2" //nolint:revive
+	. "github.com/onsi/gomega"    //nolint:revive
+	"github.com/pkg/errors"
+)
+
+const (
+	BIOS = "bios"
+	UEFI = "uefi"
+
+	qemuCmd = "qemu-system-x86_64"
+)
+
+// ovmfFirmware is an OVMF firmware with the variables template built with
+// it, the code and vars files of different builds must not be mixed
+type ovmfFirmware struct {
+	Code string
+	Vars string
+}
+
+// ovmfFirmwares lists the usual locations of the OVMF firmware
+var ovmfFirmwares = []ovmfFirmware{
+	{"/usr/share/qemu/ovmf-x86_64-code.bin", "/usr/share/qemu/ovmf-x86_64-vars.bin"},
+	{"/usr/share/OVMF/OVMF_CODE.fd", "/usr/share/OVMF/OVMF_VARS.fd"},
+	{"/usr/share/OVMF/OVMF_CODE_4M.fd", "/usr/share/OVMF/OVMF_VARS_4M.fd"},
+	{"/usr/share/edk2/ovmf/OVMF_CODE.fd", "/usr/share/edk2/ovmf/OVMF_VARS.fd"},
+	{"/usr/share/edk2/x64/OVMF_CODE.fd", "/usr/share/edk2/x64/OVMF_VARS.fd"},
+}
+
+// QEMUConfig describes the VM to launch
+type QEMUConfig struct {
+	Name       string
+	Binary     string
+	Disk       string
+	DiskFormat string
+	ISO        string
+	SeedISO    string
+	Memory     int
+	CPUs       int
+	Firmware   string
+	OVMFCode   string
+	OVMFVars   string
+	SecureBoot bool
+	TPM        bool
+	// TPMStateDir keeps the TPM state, to reuse it between VMs
+	TPMStateDir string
+	DisableKVM  bool
+	SSHPort     int
+	// Forwards maps host ports to guest ports, in addition to SSH
+	Forwards  map[int]int
+	StateDir  string
+	ExtraArgs []string
+}
+
+// QEMU is a running QEMU VM
+type QEMU struct {
+	Config    QEMUConfig
+	SUT       *SUT
+	QMPSocket string
+	SerialLog string
+	TPM       *TPM
+	cmd       *exec.Cmd
+	done      chan struct{}
+	tmpDir    bool
+}
+
+// LaunchVM starts a QEMU VM, waits for SSH and registers its teardown
+// with DeferCleanup, it must be called from a Ginkgo node
+func LaunchVM(cfg QEMUConfig) *QEMU {
+	q, err := StartQEMU(cfg)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	DeferCleanup(q.Stop)
+
+	By(fmt.Sprintf("Waiting for VM %s to be reachable on %s", q.Config.Name, q.SUT.Host))
+	q.SUT.EventuallyConnects()
+
+	return q
+}
+
+// StartQEMU starts a QEMU VM in background and returns once the process
+// is started, the returned SUT points to the forwarded SSH port
+func StartQEMU(cfg QEMUConfig) (*QEMU, error) {
+	q := &QEMU{Config: cfg, done: make(chan struct{})}
+	if err := q.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	if q.Config.TPM {
+		if err := q.startTPM(); err != nil {
+			q.cleanup()
+			return nil, err
+		}
+	}
+
+	args, err := q.Args()
+	if err != nil {
+		q.cleanup()
+		return nil, err
+	}
+
+	q.cmd = exec.Command(q.Config.Binary, args...)
+	logFile, err := os.Create(filepath.Join(q.Config.StateDir, "qemu.log"))
+	if err != nil {
+		q.cleanup()
+		return nil, err
+	}
+	q.cmd.Stdout = logFile
+	q.cmd.Stderr = logFile
+
+	if err := q.cmd.Start(); err != nil {
+		_ = logFile.Close()
+		q.cleanup()
+		return nil, errors.Wrapf(err, "starting %s", q.Config.Binary)
+	}
+
+	// Reap the process so IsVMRunning does not see a zombie
+	go func() {
+		_ = q.cmd.Wait()
+		_ = logFile.Close()
+		close(q.done)
+	}()
+
+	sut := NewSUT()
+	sut.Host = fmt.Sprintf("127.0.0.1:%d", q.Config.SSHPort)
+	sut.MachineID = q.Config.Name
+	sut.VMPid = q.cmd.Process.Pid
+	q.SUT = sut
+
+	return q, nil
+}
+
+// setDefaults fills the missing configuration values
+func (q *QEMU) setDefaults() error {
+	c := &q.Config
+
+	if c.Disk == "" && c.ISO == "" {
+		return errors.New("a disk or an ISO is required to boot the VM")
+	}
+	if c.Name == "" {
+		c.Name = "test"
+	}
+	if c.Binary == "" {
+		c.Binary = qemuCmd
+	}
+	if c.DiskFormat == "" {
+		c.DiskFormat = "qcow2"
+	}
+	if c.Memory == 0 {
+		c.Memory = 4096
+	}
+	if c.CPUs == 0 {
+		c.CPUs = 2
+	}
+	if c.Firmware == "" {
+		c.Firmware = BIOS
+	}
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		c.DisableKVM = true
+	}
+
+	if c.SSHPort == 0 {
+		port, err := FreePort()
+		if err != nil {
+			return err
+		}
+		c.SSHPort = port
+	}
+
+	if c.StateDir == "" {
+		dir, err := os.MkdirTemp("", "qemu-"+c.Name+"-")
+		if err != nil {
+			return err
+		}
+		c.StateDir = dir
+		q.tmpDir = true
+	} else if err := os.MkdirAll(c.StateDir, 0755); err != nil {
+		return err
+	}
+
+	q.QMPSocket = filepath.Join(c.StateDir, "qmp.sock")
+	q.SerialLog = filepath.Join(c.StateDir, "serial.log")
+
+	if c.Firmware == UEFI {
+		firmwares := ovmfFirmwares
+		if c.SecureBoot {
+			firmwares = ovmfSecureFirmwares
+		}
+		firmware := findOVMF(firmwares, c.OVMFCode)
+		if c.OVMFCode == "" {
+			c.OVMFCode = firmware.Code
+		}
+		if c.OVMFCode == "" {
+			return errors.New("OVMF firmware not found, please set OVMFCode")
+		}
+
+		// Each VM needs its own copy of the variables store
+		template := c.OVMFVars
+		if template == "" {
+			template = firmware.Vars
+		}
+		if template == "" {
+			return errors.New("OVMF variables template not found, please set OVMFVars")
+		}
+		vars := filepath.Join(c.StateDir, "OVMF_VARS.fd")
+		if template != vars {
+			if err := copyFile(template, vars); err != nil {
+				return err
+			}
+		}
+		c.OVMFVars = vars
+	}
+
+	return nil
+}
+
+// Args returns the QEMU command line arguments
+func (q *QEMU) Args() ([]string, error) {
+	c := q.Config
+
+	args := []string{
+		"-name", c.Name,
+		"-m", strconv.Itoa(c.Memory),
+		"-smp", strconv.Itoa(c.CPUs),
+		"-nographic",
+		"-serial", "file:" + q.SerialLog,
+		"-qmp", fmt.Sprintf("unix:%s,server=on,wait=off", q.QMPSocket),
+	}
+
+	if !c.DisableKVM {
+		args = append(args, "-enable-kvm", "-cpu", "host")
+	}
+
+	if c.Firmware == UEFI {
+		// Secure Boot needs SMM to protect the variables store
+		if c.SecureBoot {
+			args = append(args,
+				"-machine", "q35,smm=on",
+				"-global", "driver=cfi.pflash01,property=secure,value=on",
+			)
+		} else {
+			args = append(args, "-machine", "q35")
+		}
+		args = append(args,
+			"-drive", fmt.Sprintf("if=pflash,format=raw,unit=0,readonly=on,file=%s", c.OVMFCode),
+			"-drive", fmt.Sprintf("if=pflash,format=raw,unit=1,file=%s", c.OVMFVars),
+		)
+	}
+
+	// User-mode networking with SSH and extra ports forwarded
+	netdev := fmt.Sprintf("user,id=net0,hostfwd=tcp:127.0.0.1:%d-:22", c.SSHPort)
+	hostPorts := make([]int, 0, len(c.Forwards))
+	for hostPort := range c.Forwards {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Ints(hostPorts)
+	for _, hostPort := range hostPorts {
+		netdev += fmt.Sprintf(",hostfwd=tcp:127.0.0.1:%d-:%d", hostPort, c.Forwards[hostPort])
+	}
+	args = append(args,
+		"-netdev", netdev,
+		"-device", "virtio-net-pci,netdev=net0",
+	)
+
+	bootIndex := 0
+	if c.Disk != "" {
+		args = append(args,
+			"-drive", fmt.Sprintf("if=none,id=disk0,format=%s,file=%s", c.DiskFormat, c.Disk),
+			"-device", fmt.Sprintf("virtio-blk-pci,drive=disk0,bootindex=%d", bootIndex),
+		)
+		bootIndex++
+	}
+	if c.ISO != "" {
+		args = append(args,
+			"-drive", fmt.Sprintf("if=none,id=cd0,media=cdrom,readonly=on,file=%s", c.ISO),
+			"-device", fmt.Sprintf("ide-cd,drive=cd0,bootindex=%d", bootIndex),
+		)
+	}
+	if c.SeedISO != "" {
+		args = append(args,
+			"-drive", fmt.Sprintf("if=none,id=seed0,media=cdrom,readonly=on,file=%s", c.SeedISO),
+			"-device", "ide-cd,drive=seed0",
+		)
+	}
+
+	if c.TPM {
+		if q.TPM == nil {
+			return nil, errors.New("TPM is enabled but swtpm is not started")
+		}
+		args = append(args,
+			"-chardev", fmt.Sprintf("socket,id=chrtpm,path=%s", q.TPM.Socket),
+			"-tpmdev", "emulator,id=tpm0,chardev=chrtpm",
+			"-device", "tpm-tis,tpmdev=tpm0",
+		)
+	}
+
+	return append(args, c.ExtraArgs...), nil
+}
+
+// startTPM starts a swtpm instance for the VM
+func (q *QEMU) startTPM() error {
+	stateDir := q.Config.TPMStateDir
+	if stateDir == "" {
+		stateDir = filepath.Join(q.Config.StateDir, "tpm")
+	}
+
+	tpm, err := NewTPM(stateDir)
+	if err != nil {
+		return err
+	}
+	q.TPM = tpm
+
+	return tpm.Start()
+}
+
+// QMP sends a command to the QEMU monitor and returns its result
+func (q *QEMU) QMP(command string, arguments map[string]interface{}) (json.RawMessage, error) {
+	conn, err := net.DialTimeout("unix", q.QMPSocket, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	enc := json.NewEncoder(conn)
+
+	// Skip the greeting and enter command mode
+	if _, err := readQMPReply(reader); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(map[string]interface{}{"execute": "qmp_capabilities"}); err != nil {
+		return nil, err
+	}
+	if _, err := readQMPReply(reader); err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{"execute": command}
+	if arguments != nil {
+		req["arguments"] = arguments
+	}
+	if err := enc.Encode(req); err != nil {
+		return nil, err
+	}
+
+	return readQMPReply(reader)
+}
+
+// readQMPReply reads the next reply, skipping asynchronous events
+func readQMPReply(r *bufio.Reader) (json.RawMessage, error) {
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		var reply struct {
+			QMP    json.RawMessage `json:"QMP"`
+			Return json.RawMessage `json:"return"`
+			Event  string          `json:"event"`
+			Error  *struct {
+				Class string `json:"class"`
+				Desc  string `json:"desc"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &reply); err != nil {
+			return nil, errors.Wrapf(err, "invalid QMP reply '%s'", string(line))
+		}
+
+		switch {
+		case reply.Error != nil:
+			return nil, errors.Errorf("QMP error %s: %s", reply.Error.Class, reply.Error.Desc)
+		case reply.Event != "":
+			continue
+		case reply.QMP != nil:
+			return reply.QMP, nil
+		default:
+			return reply.Return, nil
+		}
+	}
+}
+
+// Stop shuts the VM down, kills it if needed and removes its temporary files
+func (q *QEMU) Stop() error {
+	defer q.cleanup()
+
+	if q.cmd == nil || q.cmd.Process == nil {
+		return nil
+	}
+
+	select {
+	case <-q.done:
+		return nil
+	default:
+	}
+
+	_, _ = q.QMP("quit", nil)
+	select {
+	case <-q.done:
+		return nil
+	case <-time.After(10 * time.Second):
+	}
+
+	if err := q.cmd.Process.Signal(syscall.SIGKILL); err != nil {
+		return err
+	}
+	<-q.done
+
+	return nil
+}
+
+// SerialOutput returns the content of the serial console log
+func (q *QEMU) SerialOutput() string {
+	out, _ := os.ReadFile(q.SerialLog)
+	return string(out)
+}
+
+// cleanup stops swtpm and removes the state directory if we created it
+func (q *QEMU) cleanup() {
+	if q.TPM != nil {
+		_ = q.TPM.Stop()
+	}
+	if q.tmpDir {
+		_ = os.RemoveAll(q.Config.StateDir)
+	}
+}
+
+// FreePort returns a free TCP port on the loopback interface
+func FreePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// findOVMF returns the first installed firmware with its variables
+// template, a firmware already chosen only selects the template of its build
+func findOVMF(firmwares []ovmfFirmware, code string) ovmfFirmware {
+	for _, f := range firmwares {
+		if code != "" && f.Code != code {
+			continue
+		}
+		if exists(f.Code) && exists(f.Vars) {
+			return f
+		}
+	}
+	return ovmfFirmware{}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func waitForFile(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.Errorf("timed out waiting for %s", path)
+}
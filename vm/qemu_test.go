@@ -0,0 +1,68 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/vm"
+)
+
+var _ = Describe("QEMU tests", func() {
+	It("Builds the QEMU command line", func() {
+		q := &vm.QEMU{
+			Config: vm.QEMUConfig{
+				Name:       "node",
+				Disk:       "/tmp/disk.qcow2",
+				DiskFormat: "qcow2",
+				ISO:        "/tmp/elemental.iso",
+				Memory:     2048,
+				CPUs:       2,
+				Firmware:   vm.UEFI,
+				OVMFCode:   "/tmp/code.fd",
+				OVMFVars:   "/tmp/vars.fd",
+				DisableKVM: true,
+				SSHPort:    2222,
+				Forwards:   map[int]int{9443: 443, 8080: 80},
+			},
+			QMPSocket: "/tmp/qmp.sock",
+			SerialLog: "/tmp/serial.log",
+		}
+
+		args, err := q.Args()
+		Expect(err).To(Not(HaveOccurred()))
+
+		cmdline := strings.Join(args, " ")
+		Expect(cmdline).To(ContainSubstring("-netdev user,id=net0,hostfwd=tcp:127.0.0.1:2222-:22,hostfwd=tcp:127.0.0.1:8080-:80,hostfwd=tcp:127.0.0.1:9443-:443"))
+		Expect(cmdline).To(ContainSubstring("if=pflash,format=raw,unit=1,file=/tmp/vars.fd"))
+		Expect(cmdline).To(ContainSubstring("virtio-blk-pci,drive=disk0,bootindex=0"))
+		Expect(cmdline).To(ContainSubstring("ide-cd,drive=cd0,bootindex=1"))
+		Expect(cmdline).To(ContainSubstring("-qmp unix:/tmp/qmp.sock,server=on,wait=off"))
+		Expect(cmdline).ToNot(ContainSubstring("-enable-kvm"))
+
+		// TPM needs a running swtpm
+		q.Config.TPM = true
+		_, err = q.Args()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Finds a free port", func() {
+		port, err := vm.FreePort()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(port).To(BeNumerically(">", 0))
+	})
+})
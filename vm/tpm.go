@@ -0,0 +1,219 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	swtpmCmd      = "swtpm"
+	swtpmSetupCmd = "swtpm_setup"
+)
+
+// TPM is a software TPM 2.0 emulated by swtpm, its state is kept in
+// StateDir so it survives VM reboots
+type TPM struct {
+	StateDir string
+	Socket   string
+	LogFile  string
+	cmd      *exec.Cmd
+	done     chan struct{}
+}
+
+// NewTPM returns a TPM storing its state in stateDir, the state is created
+// with an EK certificate if it does not exist yet
+func NewTPM(stateDir string) (*TPM, error) {
+	stateDir, err := filepath.Abs(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, err
+	}
+
+	t := &TPM{
+		StateDir: stateDir,
+		Socket:   filepath.Join(stateDir, "swtpm.sock"),
+		LogFile:  filepath.Join(stateDir, "swtpm.log"),
+	}
+
+	if !t.initialized() {
+		if err := t.setup(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// initialized returns true if swtpm_setup already ran on the state directory
+func (t *TPM) initialized() bool {
+	_, err := os.Stat(filepath.Join(t.StateDir, "tpm2-00.permall"))
+	return err == nil
+}
+
+// setup creates the TPM state, including the EK and its certificate
+func (t *TPM) setup() error {
+	out, err := exec.Command(swtpmSetupCmd, "--tpm2",
+		"--tpmstate", t.StateDir,
+		"--create-ek-cert", "--create-platform-cert",
+		"--write-ek-cert-files", t.StateDir,
+		"--overwrite").CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s failed: %s", swtpmSetupCmd, string(out))
+	}
+	return nil
+}
+
+// Start starts swtpm, QEMU connects to Socket
+func (t *TPM) Start() error {
+	if t.Running() {
+		return nil
+	}
+
+	// A stale socket would make waitForFile return too early
+	_ = os.Remove(t.Socket)
+
+	t.cmd = exec.Command(swtpmCmd, "socket", "--tpm2",
+		"--tpmstate", "dir="+t.StateDir,
+		"--ctrl", "type=unixio,path="+t.Socket,
+		"--log", "file="+t.LogFile,
+		"--terminate")
+	if err := t.cmd.Start(); err != nil {
+		return errors.Wrapf(err, "starting %s", swtpmCmd)
+	}
+
+	t.done = make(chan struct{})
+	go func() {
+		_ = t.cmd.Wait()
+		close(t.done)
+	}()
+
+	return waitForFile(t.Socket, 10*time.Second)
+}
+
+// Running returns true if swtpm is running
+func (t *TPM) Running() bool {
+	if t.done == nil {
+		return false
+	}
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stop stops swtpm, the state is kept
+func (t *TPM) Stop() error {
+	if !t.Running() {
+		return nil
+	}
+
+	if err := t.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+	select {
+	case <-t.done:
+	case <-time.After(10 * time.Second):
+		_ = t.cmd.Process.Kill()
+		<-t.done
+	}
+	_ = os.Remove(t.Socket)
+
+	return nil
+}
+
+// Reset wipes the TPM state and creates a new one, so the next boot is
+// seen as a brand new machine, swtpm is restarted if it was running
+func (t *TPM) Reset() error {
+	running := t.Running()
+	if err := t.Stop(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(t.StateDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(t.StateDir, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	if err := t.setup(); err != nil {
+		return err
+	}
+
+	if running {
+		return t.Start()
+	}
+	return nil
+}
+
+// EKCertificate returns the EK certificate written by swtpm_setup
+func (t *TPM) EKCertificate() (*x509.Certificate, error) {
+	files, err := filepath.Glob(filepath.Join(t.StateDir, "ek-*.crt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no EK certificate found in %s", t.StateDir)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificate is written in DER, accept PEM too
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	return x509.ParseCertificate(data)
+}
+
+// EKHash returns the hash of the EK, as used by the elemental-operator
+// in the MachineInventory TPM hash
+func (t *TPM) EKHash() (string, error) {
+	cert, err := t.EKCertificate()
+	if err != nil {
+		return "", err
+	}
+	return EKHash(cert)
+}
+
+// EKHash returns the SHA256 of the PKIX encoded public key of the EK
+func EKHash(cert *x509.Certificate) (string, error) {
+	data, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding EK public key")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/vm"
+)
+
+var _ = Describe("TPM tests", func() {
+	It("Computes the EK hash from the EK certificate", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).To(Not(HaveOccurred()))
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "ek"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).To(Not(HaveOccurred()))
+
+		// Fake an already initialized TPM state
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "tpm2-00.permall"), []byte{}, 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "ek-secp256r1.crt"), der, 0600)).To(Succeed())
+
+		tpm, err := vm.NewTPM(dir)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(tpm.Running()).To(BeFalse())
+
+		pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		Expect(err).To(Not(HaveOccurred()))
+
+		hash, err := tpm.EKHash()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(hash).To(Equal(fmt.Sprintf("%x", sha256.Sum256(pub))))
+	})
+})
@@ -0,0 +1,280 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	virtFwVarsCmd = "virt-fw-vars"
+
+	// EFIGlobalVariable is the GUID of the UEFI global variables (BootOrder, BootNext...)
+	EFIGlobalVariable = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+	// OVMFSecureBootGUID is the GUID of the OVMF SecureBootEnable variable
+	OVMFSecureBootGUID = "f0a30bc7-af08-4556-99c4-001009c93a44"
+
+	// UEFI variable attributes
+	EFIVariableNonVolatile       = 0x1
+	EFIVariableBootServiceAccess = 0x2
+	EFIVariableRuntimeAccess     = 0x4
+)
+
+// ovmfSecureFirmwares lists the usual locations of the OVMF firmware built
+// with SMM, with the variables template having the Microsoft keys enrolled
+var ovmfSecureFirmwares = []ovmfFirmware{
+	{"/usr/share/qemu/ovmf-x86_64-smm-code.bin", "/usr/share/qemu/ovmf-x86_64-smm-ms-vars.bin"},
+	{"/usr/share/OVMF/OVMF_CODE.secboot.fd", "/usr/share/OVMF/OVMF_VARS.ms.fd"},
+	{"/usr/share/OVMF/OVMF_CODE_4M.secboot.fd", "/usr/share/OVMF/OVMF_VARS_4M.ms.fd"},
+	{"/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd", "/usr/share/edk2/ovmf/OVMF_VARS.secboot.fd"},
+	{"/usr/share/edk2/x64/OVMF_CODE.secboot.fd", "/usr/share/edk2/x64/OVMF_VARS.secboot.fd"},
+}
+
+// UEFIVariable is a UEFI variable, as handled by virt-fw-vars JSON files
+type UEFIVariable struct {
+	Name string `json:"name"`
+	GUID string `json:"guid"`
+	Attr uint32 `json:"attr"`
+	Data string `json:"data"`
+}
+
+// uefiVariables is the virt-fw-vars JSON format
+type uefiVariables struct {
+	Version   int            `json:"version"`
+	Variables []UEFIVariable `json:"variables"`
+}
+
+// SecureBootKeys are the certificates to enroll, in PEM or DER format,
+// Owner is the GUID of the owner of the certificates
+type SecureBootKeys struct {
+	Owner string
+	PK    string
+	KEK   []string
+	DB    []string
+}
+
+// UEFIVars is an OVMF variables store, it must not be modified while the
+// VM using it is running
+type UEFIVars struct {
+	Path string
+}
+
+// NewUEFIVars copies the variables template to dst, an empty template
+// means the default OVMF template of the host
+func NewUEFIVars(template, dst string) (*UEFIVars, error) {
+	if template == "" {
+		template = findOVMF(ovmfFirmwares, "").Vars
+	}
+	if template == "" {
+		return nil, errors.New("OVMF variables template not found")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, err
+	}
+	if err := copyFile(template, dst); err != nil {
+		return nil, err
+	}
+
+	return &UEFIVars{Path: dst}, nil
+}
+
+// UEFIVars returns the variables store of the VM
+func (q *QEMU) UEFIVars() (*UEFIVars, error) {
+	if q.Config.Firmware != UEFI {
+		return nil, errors.Errorf("VM %s does not use UEFI", q.Config.Name)
+	}
+	return &UEFIVars{Path: q.Config.OVMFVars}, nil
+}
+
+// Variables returns all the variables of the store
+func (u *UEFIVars) Variables() ([]UEFIVariable, error) {
+	f, err := os.CreateTemp("", "uefi-vars-*.json")
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+	defer os.Remove(f.Name())
+
+	if err := u.virtFwVars("--output-json", f.Name()); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	vars := &uefiVariables{}
+	if err := json.Unmarshal(data, vars); err != nil {
+		return nil, errors.Wrapf(err, "invalid json '%s'", string(data))
+	}
+
+	return vars.Variables, nil
+}
+
+// Variable returns the variable with the given name and GUID
+func (u *UEFIVars) Variable(name, guid string) (*UEFIVariable, error) {
+	vars, err := u.Variables()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vars {
+		if vars[i].Name == name && strings.EqualFold(vars[i].GUID, guid) {
+			return &vars[i], nil
+		}
+	}
+
+	return nil, errors.Errorf("variable %s-%s not found", name, guid)
+}
+
+// SetVariables creates or updates variables in the store
+func (u *UEFIVars) SetVariables(vars ...UEFIVariable) error {
+	data, err := json.Marshal(&uefiVariables{Version: 2, Variables: vars})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "uefi-vars-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return u.virtFwVars("--set-json", f.Name())
+}
+
+// DeleteVariable removes a variable from the store
+func (u *UEFIVars) DeleteVariable(name string) error {
+	return u.virtFwVars("--delete", name)
+}
+
+// SetBootOrder sets the order of the BootXXXX entries
+func (u *UEFIVars) SetBootOrder(entries ...uint16) error {
+	return u.SetVariables(BootOrderVariable(entries...))
+}
+
+// SetBootNext sets the BootXXXX entry to use for the next boot only
+func (u *UEFIVars) SetBootNext(entry uint16) error {
+	return u.SetVariables(BootNextVariable(entry))
+}
+
+// SetSecureBoot enables or disables the Secure Boot enforcement, keys must
+// be enrolled for Secure Boot to be effective
+func (u *UEFIVars) SetSecureBoot(enabled bool) error {
+	return u.SetVariables(SecureBootEnableVariable(enabled))
+}
+
+// SecureBootEnabled returns true if the Secure Boot enforcement is enabled
+func (u *UEFIVars) SecureBootEnabled() (bool, error) {
+	v, err := u.Variable("SecureBootEnable", OVMFSecureBootGUID)
+	if err != nil {
+		return false, err
+	}
+	return v.Data == "01", nil
+}
+
+// EnrollKeys enrolls custom Secure Boot keys
+func (u *UEFIVars) EnrollKeys(keys SecureBootKeys) error {
+	if keys.Owner == "" {
+		return errors.New("owner GUID is required to enroll keys")
+	}
+
+	var args []string
+	if keys.PK != "" {
+		args = append(args, "--set-pk", keys.Owner, keys.PK)
+	}
+	for _, kek := range keys.KEK {
+		args = append(args, "--add-kek", keys.Owner, kek)
+	}
+	for _, db := range keys.DB {
+		args = append(args, "--add-db", keys.Owner, db)
+	}
+	if len(args) == 0 {
+		return errors.New("no key to enroll")
+	}
+
+	return u.virtFwVars(args...)
+}
+
+// virtFwVars runs virt-fw-vars in place on the store
+func (u *UEFIVars) virtFwVars(args ...string) error {
+	args = append([]string{"--input", u.Path, "--output", u.Path}, args...)
+	out, err := exec.Command(virtFwVarsCmd, args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s failed: %s", virtFwVarsCmd, string(out))
+	}
+	return nil
+}
+
+// BootOrderVariable returns the BootOrder variable for the given entries
+func BootOrderVariable(entries ...uint16) UEFIVariable {
+	data := make([]byte, 2*len(entries))
+	for i, e := range entries {
+		binary.LittleEndian.PutUint16(data[2*i:], e)
+	}
+
+	return UEFIVariable{
+		Name: "BootOrder",
+		GUID: EFIGlobalVariable,
+		Attr: EFIVariableNonVolatile | EFIVariableBootServiceAccess | EFIVariableRuntimeAccess,
+		Data: hex.EncodeToString(data),
+	}
+}
+
+// BootNextVariable returns the BootNext variable for the given entry
+func BootNextVariable(entry uint16) UEFIVariable {
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data, entry)
+
+	return UEFIVariable{
+		Name: "BootNext",
+		GUID: EFIGlobalVariable,
+		Attr: EFIVariableNonVolatile | EFIVariableBootServiceAccess | EFIVariableRuntimeAccess,
+		Data: hex.EncodeToString(data),
+	}
+}
+
+// SecureBootEnableVariable returns the OVMF SecureBootEnable variable
+func SecureBootEnableVariable(enabled bool) UEFIVariable {
+	data := "00"
+	if enabled {
+		data = "01"
+	}
+
+	return UEFIVariable{
+		Name: "SecureBootEnable",
+		GUID: OVMFSecureBootGUID,
+		Attr: EFIVariableNonVolatile | EFIVariableBootServiceAccess,
+		Data: data,
+	}
+}
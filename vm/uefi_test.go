@@ -0,0 +1,61 @@
+/*
+Copyright © 2022 - 2026 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vm_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/vm"
+)
+
+var _ = Describe("UEFI tests", func() {
+	It("Encodes boot variables", func() {
+		order := vm.BootOrderVariable(1, 0, 0x10)
+		Expect(order.Name).To(Equal("BootOrder"))
+		Expect(order.GUID).To(Equal(vm.EFIGlobalVariable))
+		Expect(order.Attr).To(BeNumerically("==", 7))
+		Expect(order.Data).To(Equal("010000001000"))
+
+		next := vm.BootNextVariable(2)
+		Expect(next.Name).To(Equal("BootNext"))
+		Expect(next.Data).To(Equal("0200"))
+
+		Expect(vm.SecureBootEnableVariable(true).Data).To(Equal("01"))
+		Expect(vm.SecureBootEnableVariable(false).Data).To(Equal("00"))
+	})
+
+	It("Enables SMM for Secure Boot", func() {
+		q := &vm.QEMU{
+			Config: vm.QEMUConfig{
+				Name:       "node",
+				Disk:       "/tmp/disk.qcow2",
+				Firmware:   vm.UEFI,
+				SecureBoot: true,
+				OVMFCode:   "/tmp/code.fd",
+				OVMFVars:   "/tmp/vars.fd",
+				DisableKVM: true,
+			},
+		}
+
+		args, err := q.Args()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(strings.Join(args, " ")).To(ContainSubstring("-machine q35,smm=on -global driver=cfi.pflash01,property=secure,value=on"))
+
+		_, err = (&vm.QEMU{Config: vm.QEMUConfig{Firmware: vm.BIOS}}).UEFIVars()
+		Expect(err).To(HaveOccurred())
+	})
+})